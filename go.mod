@@ -3,6 +3,7 @@ module github.com/theoremoon/hatenablog-atompub-client
 go 1.22.12
 
 require (
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/google/uuid v1.6.0
 	gopkg.in/yaml.v3 v3.0.1
 )
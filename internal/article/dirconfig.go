@@ -0,0 +1,83 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DirConfigFileName is a per-directory config that applies to the articles
+// in its directory and below. The nearest one wins.
+const DirConfigFileName = ".hatenablog-dir.yaml"
+
+type DirConfig struct {
+	Blog string `yaml:"blog"`
+}
+
+// ResolveBlogs sets the blog of articles without a blog in their frontmatter
+// from the nearest DirConfigFileName between the article and root.
+func ResolveBlogs(articles []*Article, root string) error {
+	root = filepath.Clean(root)
+	cache := make(map[string]*DirConfig)
+
+	for _, art := range articles {
+		if art.Blog != "" {
+			continue
+		}
+
+		dirConfig, err := findDirConfig(filepath.Dir(art.FilePath), root, cache)
+		if err != nil {
+			return err
+		}
+		if dirConfig != nil {
+			art.Blog = dirConfig.Blog
+		}
+	}
+
+	return nil
+}
+
+func findDirConfig(dir, root string, cache map[string]*DirConfig) (*DirConfig, error) {
+	dir = filepath.Clean(dir)
+	if dirConfig, ok := cache[dir]; ok {
+		return dirConfig, nil
+	}
+
+	dirConfig, err := readDirConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := filepath.Dir(dir)
+	if dirConfig == nil && dir != root && parent != dir {
+		dirConfig, err = findDirConfig(parent, root, cache)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cache[dir] = dirConfig
+	return dirConfig, nil
+}
+
+func readDirConfig(dir string) (*DirConfig, error) {
+	path := filepath.Join(dir, DirConfigFileName)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var dirConfig DirConfig
+	if err := yaml.Unmarshal(data, &dirConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &dirConfig, nil
+}
@@ -1,19 +1,78 @@
 package article
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"time"
+)
+
 type Article struct {
-	Title    string `yaml:"title"`
-	Path     string `yaml:"path"`
-	UUID     string `yaml:"uuid"`
-	Content  string
-	FilePath string
+	Title      string   `yaml:"title"`
+	Path       string   `yaml:"path,omitempty"`
+	UUID       string   `yaml:"uuid,omitempty"`
+	Categories []string `yaml:"categories,omitempty"`
+	Draft      bool     `yaml:"draft,omitempty"`
+	Date       string   `yaml:"date,omitempty"`
+	// Blog routes the article to a blog other than the configured one. It
+	// is not sent to Hatena Blog and Save never writes it.
+	Blog     string    `yaml:"blog,omitempty"`
+	DateTime time.Time `yaml:"-"`
+	Content  string    `yaml:"-"`
+	FilePath string    `yaml:"-"`
+}
+
+// Hash fingerprints everything that is sent to Hatena Blog.
+func (a *Article) Hash() string {
+	var date string
+	if !a.DateTime.IsZero() {
+		date = a.DateTime.UTC().Format(time.RFC3339)
+	}
+
+	return hashFields(a.Categories, a.Title, a.Path, strconv.FormatBool(a.Draft), date, a.Content)
+}
+
+// hashFields hashes fields followed by the sorted categories.
+func hashFields(categories []string, fields ...string) string {
+	sorted := append([]string(nil), categories...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, field := range append(fields, sorted...) {
+		// Length-prefix each field so that boundaries cannot shift
+		h.Write([]byte(strconv.Itoa(len(field)) + ":" + field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 type HatenaEntry struct {
-	ID       string
-	Title    string
-	Content  string
-	URL      string
-	EditURL  string
-	Updated  string
-	IsDraft  bool
-}
\ No newline at end of file
+	ID      string
+	Title   string
+	Content string
+	URL     string
+	// Path is the custom URL, the part of URL after /entry/.
+	Path       string
+	EditURL    string
+	Updated    string
+	Edited     string
+	IsDraft    bool
+	Categories []string
+	// Published is when the entry was first posted.
+	Published string
+}
+
+// Hash fingerprints the entry as served, to notice edits that happen within
+// the same second as LastModified.
+func (e *HatenaEntry) Hash() string {
+	return hashFields(e.Categories, e.Title, e.URL, strconv.FormatBool(e.IsDraft), e.Updated, e.Content)
+}
+
+// LastModified returns app:edited, which changes on every edit, falling back
+// to updated for servers that do not send it.
+func (e *HatenaEntry) LastModified() string {
+	if e.Edited != "" {
+		return e.Edited
+	}
+	return e.Updated
+}
@@ -5,8 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/yamlnode"
 )
 
 func ParseFile(filePath string) (*Article, error) {
@@ -19,35 +22,9 @@ func ParseFile(filePath string) (*Article, error) {
 }
 
 func ParseContent(content, filePath string) (*Article, error) {
-	lines := strings.Split(content, "\n")
-	
-	// Check if first line is opening frontmatter delimiter
-	if len(lines) == 0 || lines[0] != "---" {
-		return nil, fmt.Errorf("invalid frontmatter format in %s: missing opening ---", filePath)
-	}
-	
-	// Find the second occurrence of "---" at the beginning of a line
-	var frontmatterLines []string
-	var bodyStartIndex int
-	found := false
-	
-	for i := 1; i < len(lines); i++ {
-		if lines[i] == "---" {
-			bodyStartIndex = i + 1
-			found = true
-			break
-		}
-		frontmatterLines = append(frontmatterLines, lines[i])
-	}
-	
-	if !found {
-		return nil, fmt.Errorf("invalid frontmatter format in %s: missing closing ---", filePath)
-	}
-	
-	frontmatter := strings.Join(frontmatterLines, "\n")
-	var body string
-	if bodyStartIndex < len(lines) {
-		body = strings.Join(lines[bodyStartIndex:], "\n")
+	frontmatter, body, err := splitFrontmatter(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontmatter format in %s: %w", filePath, err)
 	}
 	body = strings.TrimSpace(body)
 
@@ -62,7 +39,34 @@ func ParseContent(content, filePath string) (*Article, error) {
 	return &article, nil
 }
 
-func LoadArticlesFromDir(dir string) ([]*Article, error) {
+// splitFrontmatter returns the YAML between the leading "---" lines and the
+// untouched body that follows.
+func splitFrontmatter(content string) (string, string, error) {
+	lines := strings.Split(content, "\n")
+
+	// Check if first line is opening frontmatter delimiter
+	if len(lines) == 0 || lines[0] != "---" {
+		return "", "", fmt.Errorf("missing opening ---")
+	}
+
+	// Find the second occurrence of "---" at the beginning of a line
+	for i := 1; i < len(lines); i++ {
+		if lines[i] == "---" {
+			return strings.Join(lines[1:i], "\n"), strings.Join(lines[i+1:], "\n"), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("missing closing ---")
+}
+
+// LoadArticlesFromDir parses every Markdown file under dir. Subdirectories
+// named in skipDirs, such as the sync state directory, are not searched.
+func LoadArticlesFromDir(dir string, skipDirs ...string) ([]*Article, error) {
+	skip := make(map[string]bool)
+	for _, name := range skipDirs {
+		skip[name] = true
+	}
+
 	var articles []*Article
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -71,6 +75,9 @@ func LoadArticlesFromDir(dir string) ([]*Article, error) {
 		}
 
 		if info.IsDir() {
+			if path != dir && skip[info.Name()] {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -92,65 +99,51 @@ func LoadArticlesFromDir(dir string) ([]*Article, error) {
 	return articles, nil
 }
 
-func UpdateArticleUUID(article *Article, uuid string) error {
-	if article.UUID != "" {
-		return fmt.Errorf("article already has UUID: %s", article.UUID)
-	}
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04",
+}
 
-	content, err := os.ReadFile(article.FilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", article.FilePath, err)
+// ParseDate parses a frontmatter date. Dates without an offset are
+// interpreted in loc.
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, fmt.Errorf("unsupported date format %q (use RFC3339 or YYYY-MM-DD HH:MM)", value)
+}
 
-	lines := strings.Split(string(content), "\n")
-	
-	// Check if first line is opening frontmatter delimiter
-	if len(lines) == 0 || lines[0] != "---" {
-		return fmt.Errorf("invalid frontmatter format in %s", article.FilePath)
-	}
-	
-	// Find the second occurrence of "---" at the beginning of a line
-	var frontmatterLines []string
-	var bodyStartIndex int
-	found := false
-	
-	for i := 1; i < len(lines); i++ {
-		if lines[i] == "---" {
-			bodyStartIndex = i + 1
-			found = true
-			break
+func ResolveDates(articles []*Article, loc *time.Location) error {
+	for _, art := range articles {
+		if art.Date == "" {
+			continue
 		}
-		frontmatterLines = append(frontmatterLines, lines[i])
-	}
-	
-	if !found {
-		return fmt.Errorf("invalid frontmatter format in %s", article.FilePath)
-	}
-	
-	frontmatter := strings.Join(frontmatterLines, "\n")
-	var body string
-	if bodyStartIndex < len(lines) {
-		body = strings.Join(lines[bodyStartIndex:], "\n")
-	}
 
-	var frontMatterMap map[string]interface{}
-	if err := yaml.Unmarshal([]byte(frontmatter), &frontMatterMap); err != nil {
-		return fmt.Errorf("failed to parse YAML frontmatter: %w", err)
+		t, err := ParseDate(art.Date, loc)
+		if err != nil {
+			return fmt.Errorf("invalid date in %s: %w", art.FilePath, err)
+		}
+		art.DateTime = t
 	}
 
-	frontMatterMap["uuid"] = uuid
+	return nil
+}
 
-	updatedFrontmatter, err := yaml.Marshal(frontMatterMap)
-	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+func UpdateArticleUUID(article *Article, uuid string) error {
+	if article.UUID != "" {
+		return fmt.Errorf("article already has UUID: %s", article.UUID)
 	}
 
-	newContent := fmt.Sprintf("---\n%s---\n%s", string(updatedFrontmatter), body)
-
-	if err := os.WriteFile(article.FilePath, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("failed to write file %s: %w", article.FilePath, err)
+	err := rewriteFile(article.FilePath, func(mapping *yaml.Node, body string) (string, error) {
+		yamlnode.Set(mapping, "uuid", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: uuid})
+		return body, nil
+	})
+	if err != nil {
+		return err
 	}
 
 	article.UUID = uuid
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,101 @@
+package article
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/yamlnode"
+)
+
+// frontmatterKeys are the keys owned by Article. Save replaces or removes
+// them and leaves every other key in the frontmatter alone.
+var frontmatterKeys = []string{"title", "path", "uuid", "categories", "draft", "date"}
+
+// Save writes the article to art.FilePath, creating the file and its parent
+// directories if needed.
+func Save(art *Article) error {
+	var fields yaml.Node
+	if err := fields.Encode(art); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	content, err := os.ReadFile(art.FilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(art.FilePath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", art.FilePath, err)
+		}
+		content, err = []byte("---\n---\n"), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", art.FilePath, err)
+	}
+
+	newContent, err := updateFrontmatter(content, art.FilePath, func(mapping *yaml.Node, _ string) (string, error) {
+		for _, key := range frontmatterKeys {
+			yamlnode.Set(mapping, key, yamlnode.Value(&fields, key))
+		}
+		return fmt.Sprintf("\n%s\n", art.Content), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(art.FilePath, newContent, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", art.FilePath, err)
+	}
+
+	return nil
+}
+
+// rewriteFile lets update modify the frontmatter and body of an existing
+// file and writes the result back.
+func rewriteFile(filePath string, update func(mapping *yaml.Node, body string) (string, error)) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	newContent, err := updateFrontmatter(content, filePath, update)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filePath, newContent, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+
+	return nil
+}
+
+func updateFrontmatter(content []byte, filePath string, update func(mapping *yaml.Node, body string) (string, error)) ([]byte, error) {
+	frontmatter, body, err := splitFrontmatter(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontmatter format in %s: %w", filePath, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(frontmatter), &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML frontmatter: %w", err)
+	}
+	mapping := yamlnode.Root(&doc)
+	if mapping == nil {
+		return nil, fmt.Errorf("invalid frontmatter format in %s: not a mapping", filePath)
+	}
+
+	body, err = update(mapping, body)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedFrontmatter, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	return []byte(fmt.Sprintf("---\n%s---\n%s", string(updatedFrontmatter), body)), nil
+}
@@ -2,34 +2,163 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"time"
+)
+
+// Authentication methods
+const (
+	AuthBasic = "basic"
+	AuthWSSE  = "wsse"
+	AuthOAuth = "oauth"
 )
 
 type Config struct {
 	HatenaID string
 	BlogID   string
 	APIKey   string
+	Location *time.Location
+	// Endpoint is the AtomPub base URL; empty means Hatena Blog.
+	Endpoint string
+	// Auth is the authentication method, AuthBasic, AuthWSSE or AuthOAuth;
+	// empty means AuthBasic.
+	Auth string
+	// OAuth holds the credentials used with AuthOAuth.
+	OAuth OAuth
+
+	// Profile is the name of the config file profile in use, if any.
+	Profile string
+	// Dir is the article directory of the profile, if set.
+	Dir string
+	// Defaults are the profile's default values for command-line flags.
+	Defaults map[string]string
+}
+
+// OAuth holds OAuth 1.0a consumer and access token credentials.
+type OAuth struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	Token          string
+	TokenSecret    string
 }
 
 func Load() (*Config, error) {
-	hatenaID := os.Getenv("HATENA_ID")
-	if hatenaID == "" {
-		return nil, fmt.Errorf("HATENA_ID environment variable is required")
+	return LoadProfile("")
+}
+
+// LoadProfile builds the configuration from the named profile of the
+// config files, overridden by environment variables. An empty name selects
+// the default profile, if any.
+func LoadProfile(name string) (*Config, error) {
+	files, err := loadFiles()
+	if err != nil {
+		return nil, err
 	}
 
-	blogID := os.Getenv("BLOG_ID")
-	if blogID == "" {
-		return nil, fmt.Errorf("BLOG_ID environment variable is required")
+	name, profile, err := selectProfile(files, name)
+	if err != nil {
+		return nil, err
 	}
 
-	apiKey := os.Getenv("API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("API_KEY environment variable is required")
+	// Environment variables take precedence over the file
+	override(&profile.HatenaID, os.Getenv("HATENA_ID"))
+	override(&profile.BlogID, os.Getenv("BLOG_ID"))
+	override(&profile.Timezone, os.Getenv("TIMEZONE"))
+	override(&profile.Endpoint, os.Getenv("HATENA_ENDPOINT"))
+	override(&profile.Auth, os.Getenv("HATENA_AUTH"))
+	override(&profile.OAuthConsumerKey, os.Getenv("HATENA_CONSUMER_KEY"))
+	override(&profile.OAuthConsumerSecret, os.Getenv("HATENA_CONSUMER_SECRET"))
+	override(&profile.OAuthToken, os.Getenv("HATENA_OAUTH_TOKEN"))
+	override(&profile.OAuthTokenSecret, os.Getenv("HATENA_OAUTH_TOKEN_SECRET"))
+
+	if profile.HatenaID == "" {
+		return nil, fmt.Errorf("HATENA_ID environment variable or hatena_id in a config profile is required")
+	}
+
+	if profile.BlogID == "" {
+		return nil, fmt.Errorf("BLOG_ID environment variable or blog_id in a config profile is required")
+	}
+
+	switch profile.Auth {
+	case "", AuthBasic, AuthWSSE, AuthOAuth:
+	default:
+		return nil, fmt.Errorf("invalid auth %q (use %s, %s or %s)", profile.Auth, AuthBasic, AuthWSSE, AuthOAuth)
+	}
+
+	oauth := OAuth{
+		ConsumerKey:    profile.OAuthConsumerKey,
+		ConsumerSecret: profile.OAuthConsumerSecret,
+		Token:          profile.OAuthToken,
+		TokenSecret:    profile.OAuthTokenSecret,
+	}
+
+	// OAuth acts with a token instead of the API key
+	var apiKey string
+	if profile.Auth == AuthOAuth {
+		if oauth.ConsumerKey == "" || oauth.ConsumerSecret == "" {
+			return nil, fmt.Errorf("OAuth requires oauth_consumer_key and oauth_consumer_secret (or HATENA_CONSUMER_KEY and HATENA_CONSUMER_SECRET)")
+		}
+		if oauth.Token == "" || oauth.TokenSecret == "" {
+			return nil, fmt.Errorf("no OAuth access token; run the login command first")
+		}
+	} else {
+		var err error
+		apiKey, err = resolveAPIKey(profile)
+		if err != nil {
+			return nil, err
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("API key is required: set API_KEY, API_KEY_FILE or API_KEY_COMMAND, or api_key, api_key_file, api_key_command or api_key_keyring in a config profile")
+		}
+	}
+
+	location := time.Local
+	if profile.Timezone != "" {
+		loc, err := time.LoadLocation(profile.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", profile.Timezone, err)
+		}
+		location = loc
+	}
+
+	if profile.Endpoint != "" {
+		if err := ValidateEndpoint(profile.Endpoint); err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %w", err)
+		}
 	}
 
 	return &Config{
-		HatenaID: hatenaID,
-		BlogID:   blogID,
+		HatenaID: profile.HatenaID,
+		BlogID:   profile.BlogID,
 		APIKey:   apiKey,
+		Location: location,
+		Endpoint: profile.Endpoint,
+		Auth:     profile.Auth,
+		OAuth:    oauth,
+		Profile:  name,
+		Dir:      profile.Dir,
+		Defaults: profile.Defaults,
 	}, nil
-}
\ No newline at end of file
+}
+
+// ValidateEndpoint checks that endpoint is an absolute http or https URL
+// that entry paths can be appended to.
+func ValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must use http or https", endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", endpoint)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("%q must not have a query or fragment", endpoint)
+	}
+
+	return nil
+}
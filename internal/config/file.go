@@ -0,0 +1,304 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/yamlnode"
+)
+
+// ProjectFileName is the project-local config file, looked up in the
+// current directory.
+const ProjectFileName = ".hatenablog.yaml"
+
+// File is the content of a config file.
+type File struct {
+	// DefaultProfile is used when no profile is selected explicitly.
+	DefaultProfile string             `yaml:"default_profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile holds the settings of one blog.
+type Profile struct {
+	HatenaID string `yaml:"hatena_id,omitempty"`
+	BlogID   string `yaml:"blog_id,omitempty"`
+	// APIKey and the other sources of the API key are only accepted in
+	// the user config file.
+	APIKey string `yaml:"api_key,omitempty"`
+	// APIKeyFile is read for the API key, relative to the file it is set
+	// in.
+	APIKeyFile string `yaml:"api_key_file,omitempty"`
+	// APIKeyCommand is run by the shell and prints the API key.
+	APIKeyCommand string `yaml:"api_key_command,omitempty"`
+	// APIKeyKeyring reads the API key from the Secret Service keyring.
+	APIKeyKeyring bool `yaml:"api_key_keyring,omitempty"`
+	// Dir is the article directory, relative to the file it is set in.
+	Dir string `yaml:"dir,omitempty"`
+	// Endpoint is only accepted in the user config file.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	Timezone string `yaml:"timezone,omitempty"`
+	// Auth is the authentication method: basic (the default), wsse or
+	// oauth.
+	Auth                string `yaml:"auth,omitempty"`
+	OAuthConsumerKey    string `yaml:"oauth_consumer_key,omitempty"`
+	OAuthConsumerSecret string `yaml:"oauth_consumer_secret,omitempty"`
+	// OAuthToken and OAuthTokenSecret are written by the login command.
+	OAuthToken       string `yaml:"oauth_token,omitempty"`
+	OAuthTokenSecret string `yaml:"oauth_token_secret,omitempty"`
+	// Defaults are default values for command-line flags, by flag name.
+	Defaults map[string]string `yaml:"defaults,omitempty"`
+}
+
+// UserFilePath returns $XDG_CONFIG_HOME/hatenablog/config.yaml, falling
+// back to ~/.config when XDG_CONFIG_HOME is unset.
+func UserFilePath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "hatenablog", "config.yaml"), nil
+}
+
+// ReadFile reads a config file. A missing file is not an error and gives
+// nil.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	// Relative paths are relative to the file, not to where we run
+	for name, profile := range file.Profiles {
+		if profile.Dir != "" && !filepath.IsAbs(profile.Dir) {
+			profile.Dir = filepath.Join(filepath.Dir(path), profile.Dir)
+		}
+		if profile.APIKeyFile != "" && !filepath.IsAbs(profile.APIKeyFile) {
+			profile.APIKeyFile = filepath.Join(filepath.Dir(path), profile.APIKeyFile)
+		}
+		file.Profiles[name] = profile
+	}
+
+	return &file, nil
+}
+
+// loadFiles reads the user and project config files, the latter taking
+// precedence.
+func loadFiles() ([]*File, error) {
+	var paths []string
+	if path, err := UserFilePath(); err == nil {
+		paths = append(paths, path)
+	}
+	paths = append(paths, ProjectFileName)
+
+	var files []*File
+	for _, path := range paths {
+		file, err := ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if file != nil && path == ProjectFileName {
+			for name, profile := range file.Profiles {
+				if key := userOnlyKey(profile); key != "" {
+					return nil, fmt.Errorf("%s in profile %q of %s is only allowed in the user config file", key, name, path)
+				}
+				// ReadFile has made dir relative to the working directory,
+				// which is where the project file is
+				if profile.Dir != "" && !filepath.IsLocal(profile.Dir) {
+					return nil, fmt.Errorf("dir in profile %q of %s must be inside the project directory", name, path)
+				}
+			}
+		}
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+// userOnlyKey returns the first key set in profile that a cloned repository
+// must not control: a source of the API key, which could read any file or
+// secret or run commands, the endpoint the credentials are sent to, or a
+// flag default outside projectDefaults.
+func userOnlyKey(profile Profile) string {
+	switch {
+	case profile.APIKey != "":
+		return "api_key"
+	case profile.APIKeyFile != "":
+		return "api_key_file"
+	case profile.APIKeyCommand != "":
+		return "api_key_command"
+	case profile.APIKeyKeyring:
+		return "api_key_keyring"
+	case profile.Endpoint != "":
+		return "endpoint"
+	}
+
+	names := make([]string, 0, len(profile.Defaults))
+	for name := range profile.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !projectDefaults[name] {
+			return "defaults." + name
+		}
+	}
+	return ""
+}
+
+// projectDefaults are the flags the project config file may set defaults
+// for. They tune how a run goes, not what it deletes, links or where it
+// sends requests.
+var projectDefaults = map[string]bool{
+	"concurrency": true,
+	"rate":        true,
+	"burst":       true,
+	"retries":     true,
+	"verbose":     true,
+	"output":      true,
+	"diff":        true,
+}
+
+// selectProfile merges the named profile across files. An empty name picks
+// HATENA_PROFILE, then default_profile, then a profile named "default"; if
+// none of these exist the result is empty.
+func selectProfile(files []*File, name string) (string, Profile, error) {
+	explicit := name != ""
+	if name == "" {
+		name = os.Getenv("HATENA_PROFILE")
+		explicit = name != ""
+	}
+	if name == "" {
+		for _, file := range files {
+			if file.DefaultProfile != "" {
+				name = file.DefaultProfile
+				explicit = true
+			}
+		}
+	}
+	if name == "" {
+		name = "default"
+	}
+
+	var merged Profile
+	found := false
+	for _, file := range files {
+		profile, ok := file.Profiles[name]
+		if !ok {
+			continue
+		}
+		found = true
+		merged.merge(profile)
+	}
+
+	if !found {
+		if explicit {
+			return "", Profile{}, fmt.Errorf("profile %q not found in config files", name)
+		}
+		return "", Profile{}, nil
+	}
+
+	return name, merged, nil
+}
+
+// merge overrides p with the fields set in other.
+func (p *Profile) merge(other Profile) {
+	override(&p.HatenaID, other.HatenaID)
+	override(&p.BlogID, other.BlogID)
+	override(&p.APIKey, other.APIKey)
+	override(&p.APIKeyFile, other.APIKeyFile)
+	override(&p.APIKeyCommand, other.APIKeyCommand)
+	p.APIKeyKeyring = p.APIKeyKeyring || other.APIKeyKeyring
+	override(&p.Dir, other.Dir)
+	override(&p.Endpoint, other.Endpoint)
+	override(&p.Timezone, other.Timezone)
+	override(&p.Auth, other.Auth)
+	override(&p.OAuthConsumerKey, other.OAuthConsumerKey)
+	override(&p.OAuthConsumerSecret, other.OAuthConsumerSecret)
+	override(&p.OAuthToken, other.OAuthToken)
+	override(&p.OAuthTokenSecret, other.OAuthTokenSecret)
+
+	for key, value := range other.Defaults {
+		if p.Defaults == nil {
+			p.Defaults = make(map[string]string)
+		}
+		p.Defaults[key] = value
+	}
+}
+
+func override(dst *string, src string) {
+	if src != "" {
+		*dst = src
+	}
+}
+
+// UpdateProfile sets the fields of update that are not empty in the named
+// profile of the config file at path. The file, the profile and missing
+// keys are created as needed; everything else, including comments, is kept.
+// The file is only readable by the user since it may hold secrets.
+func UpdateProfile(path, name string, update Profile) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	root := yamlnode.Root(&doc)
+	if root == nil {
+		return fmt.Errorf("invalid config file %s: not a mapping", path)
+	}
+
+	profile := yamlnode.Child(yamlnode.Child(root, "profiles"), name)
+
+	var fields yaml.Node
+	if err := fields.Encode(update); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	for i := 0; i+1 < len(fields.Content); i += 2 {
+		yamlnode.Set(profile, fields.Content[i].Value, fields.Content[i+1])
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	encoder.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inProject runs f in a directory whose project config file has content,
+// with a user config file that has userContent.
+func inProject(t *testing.T, content, userContent string, f func()) {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if userContent != "" {
+		userFile := filepath.Join(configHome, "hatenablog", "config.yaml")
+		if err := os.MkdirAll(filepath.Dir(userFile), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(userFile, []byte(userContent), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	f()
+}
+
+func TestLoadFilesRejectsUserOnlyKeysInProjectFile(t *testing.T) {
+	tests := map[string]string{
+		"api_key":                "api_key: stolen",
+		"api_key_file":           "api_key_file: ~/.ssh/id_rsa",
+		"api_key_command":        "api_key_command: cat ~/.ssh/id_rsa",
+		"api_key_keyring":        "api_key_keyring: true",
+		"endpoint":               "endpoint: https://attacker.example/",
+		"defaults.delete-orphan": "defaults:\n      delete-orphan: \"true\"",
+		"defaults.adopt":         "defaults:\n      adopt: \"yes\"",
+		"defaults.dir":           "defaults:\n      dir: /",
+	}
+
+	for key, line := range tests {
+		t.Run(key, func(t *testing.T) {
+			content := "profiles:\n  default:\n    blog_id: example.hatenablog.com\n    " + line + "\n"
+			inProject(t, content, "", func() {
+				_, err := loadFiles()
+				if err == nil {
+					t.Fatalf("loadFiles accepted %s in the project file", key)
+				}
+				if !strings.Contains(err.Error(), key+" in profile") {
+					t.Errorf("error %q does not name %s", err, key)
+				}
+			})
+		})
+	}
+}
+
+func TestLoadFilesProjectDir(t *testing.T) {
+	tests := map[string]struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		"inside":         {dir: "posts", want: "posts"},
+		"inside, messy":  {dir: "./posts/../drafts", want: "drafts"},
+		"project itself": {dir: ".", want: "."},
+		"absolute":       {dir: "/home/me/private-notes", wantErr: true},
+		"parent":         {dir: "../private-notes", wantErr: true},
+		"escaping":       {dir: "posts/../../private-notes", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := "profiles:\n  default:\n    blog_id: example.hatenablog.com\n    dir: " + tt.dir + "\n"
+			inProject(t, content, "", func() {
+				files, err := loadFiles()
+				if tt.wantErr {
+					if err == nil || !strings.Contains(err.Error(), "dir in profile") {
+						t.Fatalf("got error %v", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				_, profile, err := selectProfile(files, "")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if profile.Dir != tt.want {
+					t.Errorf("got dir %q, want %q", profile.Dir, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestLoadFilesAcceptsProjectDefaults(t *testing.T) {
+	project := "profiles:\n  default:\n    defaults:\n      concurrency: \"4\"\n      rate: \"2\"\n      output: json\n"
+
+	inProject(t, project, "", func() {
+		files, err := loadFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, profile, err := selectProfile(files, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.Defaults["concurrency"] != "4" || profile.Defaults["output"] != "json" {
+			t.Errorf("unexpected defaults %v", profile.Defaults)
+		}
+	})
+}
+
+func TestLoadFilesAcceptsUserOnlyKeysInUserFile(t *testing.T) {
+	user := "profiles:\n  default:\n    api_key_file: key.txt\n    api_key_keyring: true\n    endpoint: https://example.com/\n    defaults:\n      delete-orphan: \"true\"\n"
+	project := "profiles:\n  default:\n    blog_id: example.hatenablog.com\n"
+
+	inProject(t, project, user, func() {
+		files, err := loadFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, profile, err := selectProfile(files, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if profile.BlogID != "example.hatenablog.com" || profile.Endpoint != "https://example.com/" || !profile.APIKeyKeyring || profile.Defaults["delete-orphan"] != "true" {
+			t.Errorf("unexpected merged profile %+v", profile)
+		}
+	})
+}
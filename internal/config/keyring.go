@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	secretsService   = "org.freedesktop.secrets"
+	secretsPath      = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretsInterface = "org.freedesktop.Secret"
+
+	// KeyringService is the service attribute of keyring items.
+	KeyringService = "hatenablog"
+
+	// promptTimeout bounds how long we wait for the user to unlock the
+	// keyring.
+	promptTimeout = 2 * time.Minute
+)
+
+// KeyringAttributes identify the API key of hatenaID in the keyring. Store
+// it with e.g.
+//
+//	secret-tool store --label="Hatena Blog" service hatenablog hatena_id <id>
+func KeyringAttributes(hatenaID string) map[string]string {
+	return map[string]string{
+		"service":   KeyringService,
+		"hatena_id": hatenaID,
+	}
+}
+
+// secret is the Secret struct of the Secret Service API.
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// lookupKeyring reads the first item matching attributes from the
+// freedesktop Secret Service, such as GNOME Keyring or KWallet, unlocking
+// it if needed.
+func lookupKeyring(attributes map[string]string) (string, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to the session bus: %w", err)
+	}
+	defer conn.Close()
+
+	service := conn.Object(secretsService, secretsPath)
+
+	var unlocked, locked []dbus.ObjectPath
+	if err := service.Call(secretsInterface+".Service.SearchItems", 0, attributes).Store(&unlocked, &locked); err != nil {
+		return "", fmt.Errorf("failed to search the keyring: %w", err)
+	}
+
+	if len(unlocked) == 0 && len(locked) > 0 {
+		unlocked, err = unlock(conn, service, locked[:1])
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(unlocked) == 0 {
+		return "", fmt.Errorf("no item with attributes %v", attributes)
+	}
+
+	// The plain algorithm transfers the secret unencrypted over the
+	// session bus, which is private to the user
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	if err := service.Call(secretsInterface+".Service.OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return "", fmt.Errorf("failed to open a keyring session: %w", err)
+	}
+	defer conn.Object(secretsService, session).Call(secretsInterface+".Session.Close", 0)
+
+	var s secret
+	if err := conn.Object(secretsService, unlocked[0]).Call(secretsInterface+".Item.GetSecret", 0, session).Store(&s); err != nil {
+		return "", fmt.Errorf("failed to read the keyring item: %w", err)
+	}
+
+	key := strings.TrimSpace(string(s.Value))
+	if key == "" {
+		return "", fmt.Errorf("the keyring item is empty")
+	}
+	return key, nil
+}
+
+// unlock asks the Secret Service to unlock items, which may show a password
+// prompt, and returns the items that were unlocked.
+func unlock(conn *dbus.Conn, service dbus.BusObject, items []dbus.ObjectPath) ([]dbus.ObjectPath, error) {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := service.Call(secretsInterface+".Service.Unlock", 0, items).Store(&unlocked, &prompt); err != nil {
+		return nil, fmt.Errorf("failed to unlock the keyring: %w", err)
+	}
+	if prompt == "/" {
+		return unlocked, nil
+	}
+
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(secretsInterface+".Prompt"),
+		dbus.WithMatchMember("Completed"),
+	); err != nil {
+		return nil, fmt.Errorf("failed to wait for the keyring prompt: %w", err)
+	}
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.Object(secretsService, prompt).Call(secretsInterface+".Prompt.Prompt", 0, "").Err; err != nil {
+		return nil, fmt.Errorf("failed to show the keyring prompt: %w", err)
+	}
+
+	timeout := time.After(promptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != prompt || len(signal.Body) < 2 {
+				continue
+			}
+			if dismissed, _ := signal.Body[0].(bool); dismissed {
+				return nil, fmt.Errorf("unlocking the keyring was cancelled")
+			}
+			result, _ := signal.Body[1].(dbus.Variant)
+			paths, _ := result.Value().([]dbus.ObjectPath)
+			return paths, nil
+		case <-timeout:
+			return nil, fmt.Errorf("timed out waiting for the keyring to be unlocked")
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// resolveAPIKey returns the API key from the first source that is set:
+//
+//  1. API_KEY
+//  2. API_KEY_FILE
+//  3. API_KEY_COMMAND
+//  4. api_key in the profile
+//  5. api_key_file in the profile
+//  6. api_key_command in the profile
+//  7. the Secret Service keyring, if API_KEY_KEYRING or api_key_keyring
+//     is true
+//
+// An empty key means no source is set. Errors never include the key.
+func resolveAPIKey(profile Profile) (string, error) {
+	if key := os.Getenv("API_KEY"); key != "" {
+		return key, nil
+	}
+	if path := os.Getenv("API_KEY_FILE"); path != "" {
+		return readKeyFile("API_KEY_FILE", path)
+	}
+	if command := os.Getenv("API_KEY_COMMAND"); command != "" {
+		return runKeyCommand("API_KEY_COMMAND", command)
+	}
+
+	if profile.APIKey != "" {
+		return profile.APIKey, nil
+	}
+	if profile.APIKeyFile != "" {
+		return readKeyFile("api_key_file", profile.APIKeyFile)
+	}
+	if profile.APIKeyCommand != "" {
+		return runKeyCommand("api_key_command", profile.APIKeyCommand)
+	}
+
+	useKeyring := profile.APIKeyKeyring
+	if value := os.Getenv("API_KEY_KEYRING"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid API_KEY_KEYRING %q: %w", value, err)
+		}
+		useKeyring = b
+	}
+	if useKeyring {
+		key, err := lookupKeyring(KeyringAttributes(profile.HatenaID))
+		if err != nil {
+			return "", fmt.Errorf("failed to read API key from keyring: %w", err)
+		}
+		return key, nil
+	}
+
+	return "", nil
+}
+
+// readKeyFile reads a key file such as a Docker or Kubernetes secret.
+// Surrounding whitespace, including the trailing newline, is dropped.
+func readKeyFile(source, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key from %s: %w", source, err)
+	}
+
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("%s %s is empty", source, path)
+	}
+	return key, nil
+}
+
+// runKeyCommand runs command with the shell and takes the first line of its
+// output as the key, as password managers like pass print extra lines
+// after it. Its stderr and stdin are the terminal's so that it can prompt.
+func runKeyCommand(source, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	// The output is the secret, so it must not end up in the error
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %q failed: %w", source, command, err)
+	}
+
+	line, _, _ := bufio.NewReader(bytes.NewReader(output)).ReadLine()
+	key := strings.TrimSpace(string(line))
+	if key == "" {
+		return "", fmt.Errorf("%s %q printed no API key", source, command)
+	}
+	return key, nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("key commands are sh commands")
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"env.key":     "  env-file-key\n\n",
+		"profile.key": "profile-file-key\n",
+		"empty.key":   " \n",
+		"secret.key":  "top-secret\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	// Every source is set on the profile, with a keyring that cannot be
+	// reached, so each case drops the sources above the one it checks
+	all := Profile{
+		APIKey:        "profile-key",
+		APIKeyFile:    path("profile.key"),
+		APIKeyCommand: `printf 'profile-command-key\n'`,
+		APIKeyKeyring: true,
+	}
+	without := func(keys ...string) Profile {
+		p := all
+		for _, key := range keys {
+			switch key {
+			case "api_key":
+				p.APIKey = ""
+			case "api_key_file":
+				p.APIKeyFile = ""
+			case "api_key_command":
+				p.APIKeyCommand = ""
+			case "api_key_keyring":
+				p.APIKeyKeyring = false
+			}
+		}
+		return p
+	}
+	env := map[string]string{
+		"API_KEY":         "env-key",
+		"API_KEY_FILE":    path("env.key"),
+		"API_KEY_COMMAND": `printf 'env-command-key\n'`,
+	}
+
+	tests := map[string]struct {
+		env     map[string]string
+		profile Profile
+		want    string
+		// wantErr is part of the error
+		wantErr string
+	}{
+		"API_KEY first": {
+			env:     env,
+			profile: all,
+			want:    "env-key",
+		},
+		"API_KEY_FILE second": {
+			env:     map[string]string{"API_KEY_FILE": env["API_KEY_FILE"], "API_KEY_COMMAND": env["API_KEY_COMMAND"]},
+			profile: all,
+			want:    "env-file-key",
+		},
+		"API_KEY_COMMAND third": {
+			env:     map[string]string{"API_KEY_COMMAND": env["API_KEY_COMMAND"]},
+			profile: all,
+			want:    "env-command-key",
+		},
+		"api_key fourth": {
+			profile: all,
+			want:    "profile-key",
+		},
+		"api_key_file fifth": {
+			profile: without("api_key"),
+			want:    "profile-file-key",
+		},
+		"api_key_command sixth": {
+			profile: without("api_key", "api_key_file"),
+			want:    "profile-command-key",
+		},
+		"keyring last": {
+			profile: without("api_key", "api_key_file", "api_key_command"),
+			wantErr: "failed to read API key from keyring",
+		},
+		"API_KEY_KEYRING turns the keyring off": {
+			env:     map[string]string{"API_KEY_KEYRING": "false"},
+			profile: without("api_key", "api_key_file", "api_key_command"),
+		},
+		"no source": {
+			profile: without("api_key", "api_key_file", "api_key_command", "api_key_keyring"),
+		},
+		"only the first line of the command output": {
+			env:  map[string]string{"API_KEY_COMMAND": `printf '  first-line-key \nlogin: me\nurl: example.com\n'`},
+			want: "first-line-key",
+		},
+		"command output without a newline": {
+			env:  map[string]string{"API_KEY_COMMAND": `printf 'no-newline-key'`},
+			want: "no-newline-key",
+		},
+		"failing key file does not fall through": {
+			env:     map[string]string{"API_KEY_FILE": path("missing.key")},
+			profile: all,
+			wantErr: "failed to read API key from API_KEY_FILE",
+		},
+		"empty key file": {
+			profile: Profile{APIKeyFile: path("empty.key")},
+			wantErr: "api_key_file " + path("empty.key") + " is empty",
+		},
+		// The key is built by the command so that it is not in the command
+		// the error quotes
+		"command failing after printing the key": {
+			env:     map[string]string{"API_KEY_COMMAND": `printf '%s-%s\n' top secret; exit 3`},
+			wantErr: "exit status 3",
+		},
+		"command reading the key file and failing": {
+			profile: Profile{APIKeyCommand: "cat " + path("secret.key") + "; false"},
+			wantErr: "api_key_command",
+		},
+		"command printing an empty first line": {
+			env:     map[string]string{"API_KEY_COMMAND": `printf '\n%s-%s\n' top secret`},
+			wantErr: "printed no API key",
+		},
+		"invalid API_KEY_KEYRING": {
+			env:     map[string]string{"API_KEY_KEYRING": "sometimes"},
+			wantErr: `invalid API_KEY_KEYRING "sometimes"`,
+		},
+	}
+
+	keys := []string{"env-key", "env-file-key", "env-command-key", "profile-key", "profile-file-key", "profile-command-key", "top-secret"}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, name := range []string{"API_KEY", "API_KEY_FILE", "API_KEY_COMMAND", "API_KEY_KEYRING"} {
+				t.Setenv(name, tt.env[name])
+			}
+			t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+path("no-bus"))
+
+			key, err := resolveAPIKey(tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got key %q and error %v, want error %q", key, err, tt.wantErr)
+				}
+				for _, key := range keys {
+					if strings.Contains(err.Error(), key) {
+						t.Errorf("error %q includes the key %q", err, key)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != tt.want {
+				t.Errorf("got key %q, want %q", key, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Package diff compares texts line by line and formats the result as a
+// unified diff.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Kind is what an Op does to a line.
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of an edit script turning one text into another.
+type Op struct {
+	Kind Kind
+	Text string
+}
+
+// Stats counts the changed lines of a diff.
+type Stats struct {
+	Added   int
+	Removed int
+}
+
+// Options controls Unified.
+type Options struct {
+	// Context is the number of unchanged lines shown around each change.
+	Context int
+	// Color highlights the diff with ANSI escape sequences.
+	Color bool
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorCyan   = "\x1b[36m"
+	noNewlineAt = "\\ No newline at end of file"
+)
+
+// Lines returns a shortest edit script from a to b, using Myers' algorithm.
+func Lines(a, b []string) []Op {
+	// Common prefix and suffix need no search
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for _, line := range a[:prefix] {
+		ops = append(ops, Op{Equal, line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, Op{Equal, line})
+	}
+	return ops
+}
+
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	// v[offset+k] is the furthest x on diagonal k. Step d only reads the
+	// diagonals -d-1 to d+1, so only those are kept for the walk back,
+	// which takes O(D²) memory instead of O((n+m)·D).
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace back from the end, collecting operations in reverse
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v, offset := trace[d], d+1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Op{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Op{Insert, b[y-1]})
+			} else {
+				ops = append(ops, Op{Delete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// SplitLines splits text after each newline. The last line has no newline
+// if the text does not end with one.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Unified writes a unified diff from one text to another and returns the
+// number of changed lines. Nothing is written if the texts are equal.
+func Unified(w io.Writer, fromName, toName, from, to string, opts Options) (Stats, error) {
+	var stats Stats
+	ops := Lines(SplitLines(from), SplitLines(to))
+
+	// Group changes whose context overlaps into hunks of ops[start:end]
+	type hunk struct{ start, end int }
+	var hunks []hunk
+	for i, op := range ops {
+		switch op.Kind {
+		case Equal:
+			continue
+		case Delete:
+			stats.Removed++
+		case Insert:
+			stats.Added++
+		}
+
+		start := max(i-opts.Context, 0)
+		end := min(i+opts.Context+1, len(ops))
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1].end {
+			hunks[len(hunks)-1].end = end
+		} else {
+			hunks = append(hunks, hunk{start, end})
+		}
+	}
+	if len(hunks) == 0 {
+		return stats, nil
+	}
+
+	p := printer{w: w, color: opts.Color}
+	p.line(colorBold, "--- "+fromName+"\n")
+	p.line(colorBold, "+++ "+toName+"\n")
+
+	// Line numbers before each op
+	fromLine, toLine := 0, 0
+	next := 0
+	for _, h := range hunks {
+		for ; next < h.start; next++ {
+			fromLine, toLine = advance(ops[next].Kind, fromLine, toLine)
+		}
+
+		fromCount, toCount := 0, 0
+		for _, op := range ops[h.start:h.end] {
+			fromCount, toCount = advance(op.Kind, fromCount, toCount)
+		}
+		p.line(colorCyan, fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(fromLine, fromCount), hunkRange(toLine, toCount)))
+
+		for _, op := range ops[h.start:h.end] {
+			switch op.Kind {
+			case Equal:
+				p.text("", " ", op.Text)
+			case Delete:
+				p.text(colorRed, "-", op.Text)
+			case Insert:
+				p.text(colorGreen, "+", op.Text)
+			}
+		}
+	}
+
+	return stats, p.err
+}
+
+func advance(kind Kind, fromLine, toLine int) (int, int) {
+	switch kind {
+	case Equal:
+		return fromLine + 1, toLine + 1
+	case Delete:
+		return fromLine + 1, toLine
+	default:
+		return fromLine, toLine + 1
+	}
+}
+
+// hunkRange formats the start and length of a hunk side. An empty side
+// names the line before it.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// printer remembers the first write error, so that formatting can go on
+// unchecked.
+type printer struct {
+	w     io.Writer
+	color bool
+	err   error
+}
+
+func (p *printer) line(color, s string) {
+	if p.err != nil {
+		return
+	}
+	if p.color && color != "" {
+		s = color + strings.TrimSuffix(s, "\n") + colorReset + "\n"
+	}
+	_, p.err = io.WriteString(p.w, s)
+}
+
+func (p *printer) text(color, mark, text string) {
+	if strings.HasSuffix(text, "\n") {
+		p.line(color, mark+text)
+		return
+	}
+	p.line(color, mark+text+"\n")
+	p.line("", noNewlineAt+"\n")
+}
+
+// Similarity returns how alike two texts are by line, from 0 when they have
+// no line in common to 1 when they are equal.
+func Similarity(a, b string) float64 {
+	linesA, linesB := SplitLines(a), SplitLines(b)
+	if len(linesA)+len(linesB) == 0 {
+		return 1
+	}
+
+	equal := 0
+	for _, op := range Lines(linesA, linesB) {
+		if op.Kind == Equal {
+			equal++
+		}
+	}
+	return 2 * float64(equal) / float64(len(linesA)+len(linesB))
+}
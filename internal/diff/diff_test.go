@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// script renders ops as "=a +b -c".
+func script(ops []Op) string {
+	marks := map[Kind]string{Equal: "=", Delete: "-", Insert: "+"}
+	var parts []string
+	for _, op := range ops {
+		parts = append(parts, marks[op.Kind]+strings.TrimSuffix(op.Text, "\n"))
+	}
+	return strings.Join(parts, " ")
+}
+
+// numbered returns n lines "1\n" to "n\n", with the lines in changed
+// suffixed by "!".
+func numbered(n int, changed ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "%d", i)
+		for _, c := range changed {
+			if c == i {
+				b.WriteString("!")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := map[string]struct {
+		a, b string
+		want string
+	}{
+		"empty":         {"", "", ""},
+		"from empty":    {"", "a\nb\n", "+a +b"},
+		"to empty":      {"a\nb\n", "", "-a -b"},
+		"equal":         {"a\nb\n", "a\nb\n", "=a =b"},
+		"insert":        {"a\nc\n", "a\nb\nc\n", "=a +b =c"},
+		"delete":        {"a\nb\nc\n", "a\nc\n", "=a -b =c"},
+		"replace":       {"a\nb\nc\n", "a\nx\nc\n", "=a -b +x =c"},
+		"move":          {"a\nb\nc\n", "b\nc\na\n", "-a =b =c +a"},
+		"no common":     {"a\nb\n", "c\n", "-a -b +c"},
+		"interleaved":   {"a\nb\nc\nd\n", "b\nx\nd\ny\n", "-a =b -c +x =d +y"},
+		"last newline":  {"a\nb", "a\nb\n", "=a -b +b"},
+		"repeated line": {"a\na\na\n", "a\na\n", "=a =a -a"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ops := Lines(SplitLines(tt.a), SplitLines(tt.b))
+			if got := script(ops); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+
+			// The script turns a into b
+			var from, to strings.Builder
+			for _, op := range ops {
+				if op.Kind != Insert {
+					from.WriteString(op.Text)
+				}
+				if op.Kind != Delete {
+					to.WriteString(op.Text)
+				}
+			}
+			if from.String() != tt.a || to.String() != tt.b {
+				t.Errorf("script gives %q to %q", from.String(), to.String())
+			}
+		})
+	}
+}
+
+var hunkHeader = regexp.MustCompile(`(?m)^@@ .* @@$`)
+
+func TestUnified(t *testing.T) {
+	tests := map[string]struct {
+		from, to string
+		// want is the whole diff, or wantHunks its hunk headers
+		want      string
+		wantHunks []string
+		wantStats Stats
+	}{
+		"equal": {
+			from: "a\nb\n",
+			to:   "a\nb\n",
+		},
+		"both empty": {},
+		"from empty": {
+			to:        "a\nb\n",
+			want:      "--- from\n+++ to\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+			wantStats: Stats{Added: 2},
+		},
+		"to empty": {
+			from:      "a\n",
+			to:        "",
+			want:      "--- from\n+++ to\n@@ -1 +0,0 @@\n-a\n",
+			wantStats: Stats{Removed: 1},
+		},
+		"pure insert": {
+			from:      "a\nb\nc\n",
+			to:        "a\nb\nx\nc\n",
+			want:      "--- from\n+++ to\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+			wantStats: Stats{Added: 1},
+		},
+		"pure delete": {
+			from:      "a\nb\nc\n",
+			to:        "a\nc\n",
+			want:      "--- from\n+++ to\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+			wantStats: Stats{Removed: 1},
+		},
+		"missing newline at end": {
+			from:      "a\nb",
+			to:        "a\nc",
+			want:      "--- from\n+++ to\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+			wantStats: Stats{Added: 1, Removed: 1},
+		},
+		"newline added at end": {
+			from:      "a\nb",
+			to:        "a\nb\n",
+			want:      "--- from\n+++ to\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			wantStats: Stats{Added: 1, Removed: 1},
+		},
+		"context cut at both ends": {
+			from:      numbered(9),
+			to:        numbered(9, 5),
+			wantHunks: []string{"@@ -2,7 +2,7 @@"},
+			wantStats: Stats{Added: 1, Removed: 1},
+		},
+		// 6 unchanged lines between changes are all context
+		"overlapping hunks": {
+			from:      numbered(10),
+			to:        numbered(10, 2, 9),
+			wantHunks: []string{"@@ -1,10 +1,10 @@"},
+			wantStats: Stats{Added: 2, Removed: 2},
+		},
+		"separate hunks": {
+			from:      numbered(11),
+			to:        numbered(11, 2, 10),
+			wantHunks: []string{"@@ -1,5 +1,5 @@", "@@ -7,5 +7,5 @@"},
+			wantStats: Stats{Added: 2, Removed: 2},
+		},
+		"separate hunks of different lengths": {
+			from:      numbered(12),
+			to:        strings.Replace(numbered(12, 10), "2\n", "", 1),
+			wantHunks: []string{"@@ -1,5 +1,4 @@", "@@ -7,6 +6,6 @@"},
+			wantStats: Stats{Added: 1, Removed: 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			stats, err := Unified(&b, "from", "to", tt.from, tt.to, Options{Context: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != tt.wantStats {
+				t.Errorf("got %+v, want %+v", stats, tt.wantStats)
+			}
+
+			if tt.wantHunks != nil {
+				if got := hunkHeader.FindAllString(b.String(), -1); strings.Join(got, "\n") != strings.Join(tt.wantHunks, "\n") {
+					t.Errorf("got hunks %q, want %q:\n%s", got, tt.wantHunks, b.String())
+				}
+				return
+			}
+			if b.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := map[string]struct {
+		a, b string
+		want float64
+	}{
+		"both empty":    {"", "", 1},
+		"equal":         {"a\nb\n", "a\nb\n", 1},
+		"one empty":     {"a\n", "", 0},
+		"nothing alike": {"a\nb\n", "c\nd\n", 0},
+		"half alike":    {"a\nb\n", "a\nc\n", 0.5},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Similarity(tt.a, tt.b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package hatena
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+)
+
+// AtomPubClient is the set of AtomPub operations the syncer builds on.
+// *Client implements it against Hatena Blog and hatenamem.Blog in memory.
+type AtomPubClient interface {
+	// ListEntriesContext returns one page of entries, newest first. An
+	// empty pageURL means the first page.
+	ListEntriesContext(ctx context.Context, pageURL string) (*EntryPage, error)
+	GetEntryContext(ctx context.Context, entryID string) (*article.HatenaEntry, error)
+	CreateEntryContext(ctx context.Context, art *article.Article) (*article.HatenaEntry, error)
+	UpdateEntryContext(ctx context.Context, entryID string, art *article.Article) (*article.HatenaEntry, error)
+	DeleteEntryContext(ctx context.Context, entryID string) error
+}
+
+type EntryPage struct {
+	Entries []*article.HatenaEntry
+	// NextURL is the rel="next" link, empty on the last page.
+	NextURL string
+}
+
+// GetAllEntries follows the collection from the first page to the last.
+func GetAllEntries(ctx context.Context, client AtomPubClient) ([]*article.HatenaEntry, error) {
+	var allEntries []*article.HatenaEntry
+	pageURL := ""
+	pageNum := 1
+
+	for {
+		page, err := client.ListEntriesContext(ctx, pageURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch page %d: %w", pageNum, err)
+		}
+
+		// If no entries in this page, we've reached the end
+		if len(page.Entries) == 0 {
+			break
+		}
+
+		allEntries = append(allEntries, page.Entries...)
+		fmt.Fprintf(os.Stderr, "Fetched page %d: %d entries (total: %d)\n", pageNum, len(page.Entries), len(allEntries))
+
+		// If no "next" link found, we've reached the last page
+		if page.NextURL == "" {
+			break
+		}
+
+		pageURL = page.NextURL
+		pageNum++
+	}
+
+	return allEntries, nil
+}
+
+var _ AtomPubClient = (*Client)(nil)
@@ -1,12 +1,100 @@
 package hatena
 
 import (
+	"crypto/rand"
+	"crypto/sha1"
 	"encoding/base64"
 	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
 )
 
+// Authenticator adds credentials to a request. It is called for every
+// attempt, including retries.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
 func BasicAuth(hatenaID, apiKey string) string {
 	credentials := fmt.Sprintf("%s:%s", hatenaID, apiKey)
 	encoded := base64.StdEncoding.EncodeToString([]byte(credentials))
 	return fmt.Sprintf("Basic %s", encoded)
-}
\ No newline at end of file
+}
+
+// Basic authenticates with HTTP Basic authentication.
+type Basic struct {
+	HatenaID string
+	APIKey   string
+}
+
+func (a *Basic) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", BasicAuth(a.HatenaID, a.APIKey))
+	return nil
+}
+
+// WSSE authenticates with a WSSE UsernameToken in the X-WSSE header, for
+// proxies that only pass that header through.
+type WSSE struct {
+	HatenaID string
+	APIKey   string
+}
+
+func (a *WSSE) Authenticate(req *http.Request) error {
+	header, err := WSSEHeader(a.HatenaID, a.APIKey, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-WSSE", header)
+	return nil
+}
+
+// WSSEHeader builds an X-WSSE value with a fresh random nonce, so that no
+// two requests share one.
+func WSSEHeader(hatenaID, apiKey string, created time.Time) (string, error) {
+	nonce := make([]byte, 20)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate WSSE nonce: %w", err)
+	}
+
+	createdAt := created.UTC().Format(time.RFC3339)
+	return fmt.Sprintf(`UsernameToken Username="%s", PasswordDigest="%s", Nonce="%s", Created="%s"`,
+		hatenaID, WSSEDigest(nonce, createdAt, apiKey), base64.StdEncoding.EncodeToString(nonce), createdAt), nil
+}
+
+// WSSEDigest is Base64(SHA1(nonce + created + password)).
+func WSSEDigest(nonce []byte, created, apiKey string) string {
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(apiKey))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// NewAuthenticator returns the authenticator selected by cfg.Auth.
+func NewAuthenticator(cfg *config.Config) (Authenticator, error) {
+	switch cfg.Auth {
+	case "", config.AuthBasic:
+		return &Basic{HatenaID: cfg.HatenaID, APIKey: cfg.APIKey}, nil
+	case config.AuthWSSE:
+		return &WSSE{HatenaID: cfg.HatenaID, APIKey: cfg.APIKey}, nil
+	case config.AuthOAuth:
+		return &OAuth1{
+			ConsumerKey:    cfg.OAuth.ConsumerKey,
+			ConsumerSecret: cfg.OAuth.ConsumerSecret,
+			Token:          cfg.OAuth.Token,
+			TokenSecret:    cfg.OAuth.TokenSecret,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %q", cfg.Auth)
+	}
+}
+
+// WithAuthenticator overrides the authenticator selected by the config.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
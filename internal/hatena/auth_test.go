@@ -0,0 +1,93 @@
+package hatena_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// replayAuth sends the same X-WSSE header with every request.
+type replayAuth struct {
+	header string
+}
+
+func (a *replayAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("X-WSSE", a.header)
+	return nil
+}
+
+func TestAuth(t *testing.T) {
+	header, err := hatena.WSSEHeader(testHatenaID, testAPIKey, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := hatena.WSSEHeader(testHatenaID, testAPIKey, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		auth string
+		opts []hatena.Option
+		// wantStatus is the status of each of two requests; 0 is success
+		wantStatus [2]int
+	}{
+		"basic": {auth: config.AuthBasic},
+		"wsse":  {auth: config.AuthWSSE},
+		"wrong key": {
+			auth:       config.AuthBasic,
+			opts:       []hatena.Option{hatena.WithAuthenticator(&hatena.Basic{HatenaID: testHatenaID, APIKey: "wrong"})},
+			wantStatus: [2]int{http.StatusUnauthorized, http.StatusUnauthorized},
+		},
+		"replayed wsse nonce": {
+			opts:       []hatena.Option{hatena.WithAuthenticator(&replayAuth{header})},
+			wantStatus: [2]int{0, http.StatusUnauthorized},
+		},
+		"stale wsse token": {
+			opts:       []hatena.Option{hatena.WithAuthenticator(&replayAuth{stale})},
+			wantStatus: [2]int{http.StatusUnauthorized, http.StatusUnauthorized},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+			client := newClient(t, srv, tt.auth, &bytes.Buffer{}, tt.opts...)
+
+			for i, want := range tt.wantStatus {
+				_, err := client.ListEntriesContext(context.Background(), "")
+				if got := statusOf(err); got != want || (want == 0 && err != nil) {
+					t.Errorf("request %d: got %v, want status %d", i+1, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewClientAuth(t *testing.T) {
+	tests := map[string]struct {
+		auth    string
+		opts    []hatena.Option
+		wantErr bool
+	}{
+		"default":       {},
+		"oauth":         {auth: config.AuthOAuth},
+		"unknown":       {auth: "digest", wantErr: true},
+		"authenticator": {auth: "digest", opts: []hatena.Option{hatena.WithAuthenticator(&hatena.Basic{})}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &config.Config{HatenaID: testHatenaID, BlogID: testBlogID, APIKey: testAPIKey, Auth: tt.auth}
+			client, err := hatena.NewClient(cfg, tt.opts...)
+			if (err != nil) != tt.wantErr || (err == nil) != (client != nil) {
+				t.Errorf("got client %v and error %v, want error %t", client, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,36 +2,84 @@ package hatena
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"log"
+	"maps"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
 	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
 )
 
+// DefaultBaseURL is the AtomPub endpoint of Hatena Blog.
+const DefaultBaseURL = "https://blog.hatena.ne.jp/"
+
 type Client struct {
-	config     *config.Config
-	httpClient *http.Client
+	config      *config.Config
+	baseURL     string
+	auth        Authenticator
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	limiter     *RateLimiter
+	logger      *log.Logger
+
+	// known holds the IDs of the entries on the first page before the
+	// first POST and of every entry created since, see knownEntryIDs.
+	knownMu sync.Mutex
+	known   map[string]bool
+}
+
+type Option func(*Client)
+
+// WithBaseURL points the client at another AtomPub endpoint, such as a
+// hatenatest server. It takes precedence over config.Config.Endpoint.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithLogger sets where verbose diagnostics such as retries are logged.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// APIError is returned when the API responds with an unexpected status.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by a Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
 }
 
 type AtomEntry struct {
-	XMLName     xml.Name `xml:"entry"`
-	Xmlns       string   `xml:"xmlns,attr"`
-	XmlnsApp    string   `xml:"xmlns:app,attr"`
-	ID          string   `xml:"id,omitempty"`
-	Title       string   `xml:"title"`
-	Content     Content  `xml:"content"`
-	Updated     string   `xml:"updated,omitempty"`
-	Published   string   `xml:"published,omitempty"`
-	Link        []Link   `xml:"link,omitempty"`
-	Control     *Control `xml:"app:control,omitempty"`
-	CustomURL   string   `xml:"hatenablog:custom-url,omitempty"`
-	XmlnsHatena string   `xml:"xmlns:hatenablog,attr,omitempty"`
+	XMLName     xml.Name   `xml:"entry"`
+	Xmlns       string     `xml:"xmlns,attr"`
+	XmlnsApp    string     `xml:"xmlns:app,attr"`
+	ID          string     `xml:"id,omitempty"`
+	Title       string     `xml:"title"`
+	Content     Content    `xml:"content"`
+	Updated     string     `xml:"updated,omitempty"`
+	Published   string     `xml:"published,omitempty"`
+	Edited      string     `xml:"app:edited,omitempty"`
+	Link        []Link     `xml:"link,omitempty"`
+	Category    []Category `xml:"category,omitempty"`
+	Control     *Control   `xml:"app:control,omitempty"`
+	CustomURL   string     `xml:"hatenablog:custom-url,omitempty"`
+	XmlnsHatena string     `xml:"xmlns:hatenablog,attr,omitempty"`
 }
 
 type Content struct {
@@ -44,10 +92,42 @@ type Link struct {
 	Href string `xml:"href,attr"`
 }
 
+type Category struct {
+	Term string `xml:"term,attr"`
+}
+
 type Control struct {
 	Draft string `xml:"app:draft"`
 }
 
+// UnmarshalXML decodes namespaced elements such as app:control. encoding/xml
+// resolves prefixes to namespace URLs when decoding, so the prefixed tags
+// used for marshalling never match on the way back in.
+func (e *AtomEntry) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// Entry drops this method so decoding does not recurse.
+	type Entry AtomEntry
+	var decoded struct {
+		Entry
+		Control *struct {
+			Draft string `xml:"http://www.w3.org/2007/app draft"`
+		} `xml:"http://www.w3.org/2007/app control"`
+		Edited    string `xml:"http://www.w3.org/2007/app edited"`
+		CustomURL string `xml:"http://www.hatena.ne.jp/info/xmlns#hatenablog custom-url"`
+	}
+	if err := d.DecodeElement(&decoded, &start); err != nil {
+		return err
+	}
+
+	*e = AtomEntry(decoded.Entry)
+	e.Edited = decoded.Edited
+	e.CustomURL = decoded.CustomURL
+	if decoded.Control != nil {
+		e.Control = &Control{Draft: decoded.Control.Draft}
+	}
+
+	return nil
+}
+
 type AtomFeed struct {
 	XMLName xml.Name    `xml:"feed"`
 	Xmlns   string      `xml:"xmlns,attr"`
@@ -55,29 +135,146 @@ type AtomFeed struct {
 	Link    []Link      `xml:"link"`
 }
 
-func NewClient(cfg *config.Config) *Client {
-	return &Client{
-		config:     cfg,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+// NewClient returns a client for the blog of cfg, authenticating as
+// cfg.Auth selects unless WithAuthenticator is given.
+func NewClient(cfg *config.Config, opts ...Option) (*Client, error) {
+	c := &Client{
+		config:      cfg,
+		baseURL:     DefaultBaseURL,
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		retryPolicy: DefaultRetryPolicy,
+		limiter:     NewRateLimiter(DefaultRequestsPerSecond, DefaultBurst),
+		logger:      log.New(io.Discard, "", 0),
+	}
+
+	if cfg.Endpoint != "" {
+		c.baseURL = cfg.Endpoint
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.auth == nil {
+		auth, err := NewAuthenticator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.auth = auth
+	}
+
+	return c, nil
 }
 
+func newAtomEntry(art *article.Article) *AtomEntry {
+	entry := &AtomEntry{
+		Xmlns:       "http://www.w3.org/2005/Atom",
+		XmlnsApp:    "http://www.w3.org/2007/app",
+		XmlnsHatena: "http://www.hatena.ne.jp/info/xmlns#hatenablog",
+		Title:       art.Title,
+		Content: Content{
+			Text: art.Content,
+		},
+		CustomURL: art.Path,
+		Control:   &Control{Draft: "no"},
+	}
+
+	if art.Draft {
+		entry.Control.Draft = "yes"
+	}
+
+	if !art.DateTime.IsZero() {
+		entry.Updated = art.DateTime.Format(time.RFC3339)
+	}
+
+	for _, category := range art.Categories {
+		entry.Category = append(entry.Category, Category{Term: category})
+	}
+
+	return entry
+}
+
+// EntryToAtom returns the Atom representation of an entry as the API serves
+// it.
+func EntryToAtom(entry *article.HatenaEntry) *AtomEntry {
+	draft := "no"
+	if entry.IsDraft {
+		draft = "yes"
+	}
+
+	atom := &AtomEntry{
+		Xmlns:       "http://www.w3.org/2005/Atom",
+		XmlnsApp:    "http://www.w3.org/2007/app",
+		XmlnsHatena: "http://www.hatena.ne.jp/info/xmlns#hatenablog",
+		ID:          entry.ID,
+		Title:       entry.Title,
+		Content:     Content{Type: "text/x-markdown", Text: entry.Content},
+		Updated:     entry.Updated,
+		Published:   entry.Published,
+		Edited:      entry.Edited,
+		Link: []Link{
+			{Rel: "edit", Href: entry.EditURL},
+			{Rel: "alternate", Href: entry.URL},
+		},
+		Control:   &Control{Draft: draft},
+		CustomURL: entry.Path,
+	}
+	for _, category := range entry.Categories {
+		atom.Category = append(atom.Category, Category{Term: category})
+	}
+
+	return atom
+}
+
+func toHatenaEntry(atomEntry *AtomEntry) *article.HatenaEntry {
+	entry := &article.HatenaEntry{
+		ID:        atomEntry.ID,
+		Title:     atomEntry.Title,
+		Content:   atomEntry.Content.Text,
+		Updated:   atomEntry.Updated,
+		Published: atomEntry.Published,
+		Edited:    atomEntry.Edited,
+		IsDraft:   atomEntry.Control != nil && atomEntry.Control.Draft == "yes",
+	}
+
+	for _, category := range atomEntry.Category {
+		entry.Categories = append(entry.Categories, category.Term)
+	}
+
+	for _, link := range atomEntry.Link {
+		if link.Rel == "alternate" {
+			entry.URL = link.Href
+		} else if link.Rel == "edit" {
+			entry.EditURL = link.Href
+		}
+	}
+
+	// Servers that do not send the custom URL still show it in the link
+	entry.Path = atomEntry.CustomURL
+	if entry.Path == "" {
+		entry.Path = ExtractPathFromURL(entry.URL)
+	}
+
+	return entry
+}
 
 func (c *Client) getCollectionURL() string {
-	return fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/atom/entry", c.config.HatenaID, c.config.BlogID)
+	return fmt.Sprintf("%s/%s/%s/atom/entry", strings.TrimSuffix(c.baseURL, "/"), c.config.HatenaID, c.config.BlogID)
 }
 
 func (c *Client) getMemberURL(entryID string) string {
-	return fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/atom/entry/%s", c.config.HatenaID, c.config.BlogID, entryID)
+	return fmt.Sprintf("%s/%s", c.getCollectionURL(), entryID)
 }
 
-func (c *Client) createRequest(method, url string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+func (c *Client) createRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", BasicAuth(c.config.HatenaID, c.config.APIKey))
+	if err := c.auth.Authenticate(req); err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
 	req.Header.Set("User-Agent", "hatenablog-atompub-client/1.0")
 
@@ -85,168 +282,273 @@ func (c *Client) createRequest(method, url string, body io.Reader) (*http.Reques
 }
 
 func (c *Client) GetEntries() ([]*article.HatenaEntry, error) {
-	var allEntries []*article.HatenaEntry
-	currentURL := c.getCollectionURL()
-	pageNum := 1
+	return c.GetEntriesContext(context.Background())
+}
 
-	for {
-		req, err := c.createRequest("GET", currentURL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create request for page %d: %w", pageNum, err)
-		}
+func (c *Client) GetEntriesContext(ctx context.Context) ([]*article.HatenaEntry, error) {
+	return GetAllEntries(ctx, c)
+}
 
-		resp, err := c.httpClient.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("failed to execute request for page %d: %w", pageNum, err)
-		}
-		defer resp.Body.Close()
+// ListEntriesContext fetches one page of the collection. An empty pageURL
+// means the first page.
+func (c *Client) ListEntriesContext(ctx context.Context, pageURL string) (*EntryPage, error) {
+	if pageURL == "" {
+		pageURL = c.getCollectionURL()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API request failed with status %d for page %d: %s", resp.StatusCode, pageNum, string(body))
-		}
+	body, err := c.do(ctx, "GET", pageURL, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
 
-		var feed AtomFeed
-		if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
-			return nil, fmt.Errorf("failed to decode XML for page %d: %w", pageNum, err)
-		}
+	var feed AtomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to decode XML: %w", err)
+	}
+
+	page := &EntryPage{}
+	for i := range feed.Entry {
+		page.Entries = append(page.Entries, toHatenaEntry(&feed.Entry[i]))
+	}
 
-		// If no entries in this page, we've reached the end
-		if len(feed.Entry) == 0 {
+	// Look for rel="next" link to get next page URL
+	for _, link := range feed.Link {
+		if link.Rel == "next" {
+			page.NextURL = link.Href
 			break
 		}
+	}
 
-		var entries []*article.HatenaEntry
-		for _, atomEntry := range feed.Entry {
-			entry := &article.HatenaEntry{
-				ID:      atomEntry.ID,
-				Title:   atomEntry.Title,
-				Content: atomEntry.Content.Text,
-				Updated: atomEntry.Updated,
-				IsDraft: atomEntry.Control != nil && atomEntry.Control.Draft == "yes",
-			}
+	return page, nil
+}
 
-			for _, link := range atomEntry.Link {
-				if link.Rel == "alternate" {
-					entry.URL = link.Href
-				} else if link.Rel == "edit" {
-					entry.EditURL = link.Href
-				}
-			}
+func (c *Client) GetEntryContext(ctx context.Context, entryID string) (*article.HatenaEntry, error) {
+	responseBody, err := c.do(ctx, "GET", c.getMemberURL(entryID), nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
 
-			entries = append(entries, entry)
-		}
+	return DecodeEntry(responseBody)
+}
 
-		allEntries = append(allEntries, entries...)
-		fmt.Printf("Fetched page %d: %d entries (total: %d)\n", pageNum, len(entries), len(allEntries))
+// CreateEntry posts a new entry. POST is not idempotent, so a failed attempt
+// is only retried once the server has rejected it outright or the entry is
+// confirmed not to exist.
+func (c *Client) CreateEntry(art *article.Article) (*article.HatenaEntry, error) {
+	return c.CreateEntryContext(context.Background(), art)
+}
 
-		// Look for rel="next" link to get next page URL
-		var nextURL string
-		for _, link := range feed.Link {
-			if link.Rel == "next" {
-				nextURL = link.Href
-				break
+func (c *Client) CreateEntryContext(ctx context.Context, art *article.Article) (*article.HatenaEntry, error) {
+	xmlData, err := xml.Marshal(newAtomEntry(art))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal XML: %w", err)
+	}
+
+	// The entries known before posting tell an entry a failed POST created
+	// from older ones. Without them a failed POST is never retried.
+	known, err := c.knownEntryIDs(ctx)
+	if err != nil {
+		c.logger.Printf("Failed to list entries before POST %s: %v", c.getCollectionURL(), err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		responseBody, err := c.send(ctx, "POST", c.getCollectionURL(), xmlData, http.StatusCreated)
+		if err == nil {
+			created, err := DecodeEntry(responseBody)
+			if err != nil {
+				return nil, err
 			}
+			c.addKnownEntry(created.ID)
+			return created, nil
 		}
 
-		// If no "next" link found, we've reached the last page
-		if nextURL == "" {
-			break
+		delay, retryable := c.retryDelay(ctx, err, attempt)
+		if !retryable {
+			return nil, err
 		}
 
-		currentURL = nextURL
-		pageNum++
-		
-		// Add a small delay to be respectful to the API
-		time.Sleep(100 * time.Millisecond)
+		if !isRejected(err) {
+			created, lookupErr := c.findCreatedEntry(ctx, art, known)
+			if lookupErr != nil {
+				c.logger.Printf("Not retrying POST %s: could not confirm whether the entry was created: %v", c.getCollectionURL(), lookupErr)
+				return nil, err
+			}
+			if created != nil {
+				c.logger.Printf("POST %s failed but the entry was created: %s", c.getCollectionURL(), created.ID)
+				c.addKnownEntry(created.ID)
+				return created, nil
+			}
+		}
+
+		c.logRetry("POST", c.getCollectionURL(), attempt, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
+}
+
+// knownEntryIDs returns a copy of the entries known to the client. The
+// first page is only listed before the first POST, rather than before
+// every one, and the entries the client creates are added as it goes, so
+// that bulk posting does not double the number of requests.
+func (c *Client) knownEntryIDs(ctx context.Context) (map[string]bool, error) {
+	c.knownMu.Lock()
+	defer c.knownMu.Unlock()
 
-	return allEntries, nil
+	if c.known == nil {
+		ids, err := c.newestEntryIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.known = ids
+	}
+	return maps.Clone(c.known), nil
 }
 
-func (c *Client) CreateEntry(art *article.Article) (*article.HatenaEntry, error) {
-	entry := &AtomEntry{
-		Xmlns:       "http://www.w3.org/2005/Atom",
-		XmlnsApp:    "http://www.w3.org/2007/app",
-		XmlnsHatena: "http://www.hatena.ne.jp/info/xmlns#hatenablog",
-		Title:       art.Title,
-		Content: Content{
-			Text: art.Content,
-		},
-		CustomURL: art.Path,
+func (c *Client) addKnownEntry(id string) {
+	c.knownMu.Lock()
+	defer c.knownMu.Unlock()
+
+	if c.known != nil {
+		c.known[id] = true
 	}
+}
 
-	xmlData, err := xml.Marshal(entry)
+// newestEntryIDs returns the IDs of the entries on the first page.
+func (c *Client) newestEntryIDs(ctx context.Context) (map[string]bool, error) {
+	page, err := c.ListEntriesContext(ctx, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal XML: %w", err)
+		return nil, err
 	}
 
+	ids := make(map[string]bool)
+	for _, entry := range page.Entries {
+		ids[entry.ID] = true
+	}
+	return ids, nil
+}
 
-	req, err := c.createRequest("POST", c.getCollectionURL(), bytes.NewReader(xmlData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// findCreatedEntry looks for art among the entries of the first page that
+// were not there before posting, which is where a POST that failed after
+// reaching the server would have put it. It returns nil if the entry was
+// certainly not created, and an error if that cannot be told.
+func (c *Client) findCreatedEntry(ctx context.Context, art *article.Article, known map[string]bool) (*article.HatenaEntry, error) {
+	if known == nil {
+		return nil, fmt.Errorf("entries before posting are unknown")
 	}
 
-	resp, err := c.httpClient.Do(req)
+	page, err := c.ListEntriesContext(ctx, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	var candidates []*article.HatenaEntry
+	sawKnown := false
+	for _, entry := range page.Entries {
+		if known[entry.ID] {
+			sawKnown = true
+			continue
+		}
+		if entry.Title == art.Title {
+			candidates = append(candidates, entry)
+		}
 	}
 
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+	if page.NextURL != "" && len(page.Entries) > 0 {
+		// The entry may be on a later page if new entries pushed every
+		// known one off the first, or if it is dated before them.
+		if !sawKnown {
+			return nil, fmt.Errorf("the first page holds only new entries")
+		}
+		if !art.DateTime.IsZero() {
+			oldest, err := time.Parse(time.RFC3339, page.Entries[len(page.Entries)-1].Updated)
+			if err != nil || art.DateTime.Before(oldest) {
+				return nil, fmt.Errorf("the article is dated before the entries of the first page")
+			}
+		}
 	}
 
+	switch {
+	case len(candidates) == 0:
+		return nil, nil
+	case len(candidates) == 1 && sameContent(candidates[0].Content, art.Content):
+		return candidates[0], nil
+	default:
+		return nil, fmt.Errorf("%d new entries titled %q do not match the article exactly", len(candidates), art.Title)
+	}
+}
 
-	var createdEntry AtomEntry
-	if err := xml.Unmarshal(responseBody, &createdEntry); err != nil {
-		return nil, fmt.Errorf("failed to decode response XML: %w", err)
+// sameContent compares contents ignoring line endings and surrounding
+// whitespace, which the server may normalize.
+func sameContent(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
 	}
+	return normalize(a) == normalize(b)
+}
 
-	result := &article.HatenaEntry{
-		ID:      createdEntry.ID,
-		Title:   createdEntry.Title,
-		Content: createdEntry.Content.Text,
-		Updated: createdEntry.Updated,
-		IsDraft: createdEntry.Control != nil && createdEntry.Control.Draft == "yes",
+func (c *Client) UpdateEntry(entryID string, art *article.Article) (*article.HatenaEntry, error) {
+	return c.UpdateEntryContext(context.Background(), entryID, art)
+}
+
+func (c *Client) UpdateEntryContext(ctx context.Context, entryID string, art *article.Article) (*article.HatenaEntry, error) {
+	xmlData, err := xml.Marshal(newAtomEntry(art))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal XML: %w", err)
 	}
 
-	for _, link := range createdEntry.Link {
-		if link.Rel == "alternate" {
-			result.URL = link.Href
-		} else if link.Rel == "edit" {
-			result.EditURL = link.Href
-		}
+	responseBody, err := c.do(ctx, "PUT", c.getMemberURL(entryID), xmlData, http.StatusOK)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return DecodeEntry(responseBody)
 }
 
-func (c *Client) UpdateEntry(entryID string, art *article.Article) (*article.HatenaEntry, error) {
-	entry := &AtomEntry{
-		Xmlns:       "http://www.w3.org/2005/Atom",
-		XmlnsApp:    "http://www.w3.org/2007/app",
-		XmlnsHatena: "http://www.hatena.ne.jp/info/xmlns#hatenablog",
-		Title:       art.Title,
-		Content: Content{
-			Text: art.Content,
-		},
-		CustomURL: art.Path,
+func (c *Client) DeleteEntry(entryID string) error {
+	return c.DeleteEntryContext(context.Background(), entryID)
+}
+
+func (c *Client) DeleteEntryContext(ctx context.Context, entryID string) error {
+	_, err := c.do(ctx, "DELETE", c.getMemberURL(entryID), nil, http.StatusOK, http.StatusNoContent)
+	return err
+}
+
+// do sends an idempotent request, retrying transient failures according to
+// the retry policy.
+func (c *Client) do(ctx context.Context, method, url string, body []byte, want ...int) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		responseBody, err := c.send(ctx, method, url, body, want...)
+		if err == nil {
+			return responseBody, nil
+		}
+
+		delay, retryable := c.retryDelay(ctx, err, attempt)
+		if !retryable {
+			return nil, err
+		}
+
+		c.logRetry(method, url, attempt, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	xmlData, err := xml.Marshal(entry)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal XML: %w", err)
+// send performs a single request and returns the response body. Responses
+// whose status is not one of want are returned as *APIError.
+func (c *Client) send(ctx context.Context, method, url string, body []byte, want ...int) ([]byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
 	}
 
+	// Wait first, so that WSSE and OAuth timestamps are fresh when sent
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
 
-	req, err := c.createRequest("PUT", c.getMemberURL(entryID), bytes.NewReader(xmlData))
+	req, err := c.createRequest(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -257,59 +559,32 @@ func (c *Client) UpdateEntry(entryID string, art *article.Article) (*article.Hat
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-
-	var updatedEntry AtomEntry
-	if err := xml.Unmarshal(responseBody, &updatedEntry); err != nil {
-		return nil, fmt.Errorf("failed to decode response XML: %w", err)
-	}
-
-	result := &article.HatenaEntry{
-		ID:      updatedEntry.ID,
-		Title:   updatedEntry.Title,
-		Content: updatedEntry.Content.Text,
-		Updated: updatedEntry.Updated,
-		IsDraft: updatedEntry.Control != nil && updatedEntry.Control.Draft == "yes",
-	}
-
-	for _, link := range updatedEntry.Link {
-		if link.Rel == "alternate" {
-			result.URL = link.Href
-		} else if link.Rel == "edit" {
-			result.EditURL = link.Href
+	for _, status := range want {
+		if resp.StatusCode == status {
+			return responseBody, nil
 		}
 	}
 
-	return result, nil
-}
-
-func (c *Client) DeleteEntry(entryID string) error {
-	req, err := c.createRequest("DELETE", c.getMemberURL(entryID), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	return nil, &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(responseBody),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
 	}
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+// DecodeEntry parses an Atom entry document.
+func DecodeEntry(data []byte) (*article.HatenaEntry, error) {
+	var entry AtomEntry
+	if err := xml.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to decode response XML: %w", err)
 	}
 
-	return nil
+	return toHatenaEntry(&entry), nil
 }
 
 func ExtractEntryIDFromEditURL(editURL string) string {
@@ -321,6 +596,17 @@ func ExtractEntryIDFromEditURL(editURL string) string {
 	return ""
 }
 
+// ExtractPathFromURL returns the custom URL part of an entry's alternate
+// URL, e.g. "2024/01/01/hello" for https://example.hatenablog.com/entry/2024/01/01/hello.
+func ExtractPathFromURL(entryURL string) string {
+	re := regexp.MustCompile(`/entry/(.+)$`)
+	matches := re.FindStringSubmatch(entryURL)
+	if len(matches) >= 2 {
+		return matches[1]
+	}
+	return ""
+}
+
 func ExtractUUIDFromEntryID(entryID string) string {
 	parts := strings.Split(entryID, "-")
 	if len(parts) >= 3 {
@@ -328,5 +614,3 @@ func ExtractUUIDFromEntryID(entryID string) string {
 	}
 	return ""
 }
-
-
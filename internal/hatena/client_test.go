@@ -0,0 +1,90 @@
+package hatena_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenatest"
+)
+
+const (
+	testHatenaID = "me"
+	testBlogID   = "example.hatenablog.com"
+	testAPIKey   = "secret"
+)
+
+// fastRetries retries quickly, so that tests do not wait for backoff.
+var fastRetries = hatena.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond}
+
+func newServer(t *testing.T) *hatenatest.Server {
+	t.Helper()
+
+	srv := hatenatest.NewServer(testHatenaID, testBlogID, testAPIKey)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newClient returns a client of srv that logs retries into logs.
+func newClient(t *testing.T, srv *hatenatest.Server, auth string, logs *bytes.Buffer, opts ...hatena.Option) *hatena.Client {
+	t.Helper()
+
+	cfg := &config.Config{HatenaID: testHatenaID, BlogID: testBlogID, APIKey: testAPIKey, Auth: auth}
+	opts = append([]hatena.Option{
+		hatena.WithBaseURL(srv.URL),
+		hatena.WithRetryPolicy(fastRetries),
+		hatena.WithRateLimiter(hatena.NewRateLimiter(0, 1)),
+		hatena.WithLogger(log.New(logs, "", 0)),
+	}, opts...)
+	client, err := hatena.NewClient(cfg, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func statusOf(err error) int {
+	var apiErr *hatena.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func TestGetAllEntriesPages(t *testing.T) {
+	srv := newServer(t)
+	srv.Blog.PageSize = 3
+	for _, title := range []string{"1", "2", "3", "4", "5", "6", "7"} {
+		if _, err := srv.Blog.CreateEntryContext(context.Background(), &article.Article{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := newClient(t, srv, "", &bytes.Buffer{})
+
+	page, err := client.ListEntriesContext(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 3 || !strings.Contains(page.NextURL, "?page=") {
+		t.Fatalf("first page has %d entries and next URL %q", len(page.Entries), page.NextURL)
+	}
+
+	entries, err := hatena.GetAllEntries(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, entry := range entries {
+		titles = append(titles, entry.Title)
+	}
+	if got := strings.Join(titles, ","); got != "7,6,5,4,3,2,1" {
+		t.Errorf("got entries %s", got)
+	}
+}
@@ -0,0 +1,258 @@
+// Package hatenamem provides an in-memory Hatena Blog that implements
+// hatena.AtomPubClient, for testing code built on the syncer without
+// network access.
+package hatenamem
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+const (
+	// DefaultPageSize matches the number of entries per page on Hatena Blog.
+	DefaultPageSize = 10
+
+	// Entry and blog numbers on Hatena Blog are large decimal numbers.
+	firstEntryNumber = 13574176438000000000
+	blogNumber       = "10328749687000000000"
+)
+
+// Blog is an in-memory blog. Entries are listed newest first, PageSize per
+// page, and carry IDs, links and draft flags shaped like the real ones.
+// It is safe for concurrent use.
+type Blog struct {
+	HatenaID string
+	BlogID   string
+	PageSize int
+	// BaseURL is the AtomPub endpoint that edit and next links point at.
+	BaseURL string
+	// Now is the clock used for published, updated and edited times.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	entries    []*entry
+	nextNumber uint64
+}
+
+type entry struct {
+	number    uint64
+	customURL string
+	published time.Time
+	data      article.HatenaEntry
+}
+
+var _ hatena.AtomPubClient = (*Blog)(nil)
+
+func New(hatenaID, blogID string) *Blog {
+	return &Blog{
+		HatenaID:   hatenaID,
+		BlogID:     blogID,
+		PageSize:   DefaultPageSize,
+		BaseURL:    hatena.DefaultBaseURL,
+		Now:        time.Now,
+		nextNumber: firstEntryNumber,
+	}
+}
+
+// CollectionURL is the URL of the first page, as used by hatena.Client.
+func (b *Blog) CollectionURL() string {
+	return fmt.Sprintf("%s/%s/%s/atom/entry", strings.TrimSuffix(b.BaseURL, "/"), b.HatenaID, b.BlogID)
+}
+
+func (b *Blog) ListEntriesContext(ctx context.Context, pageURL string) (*hatena.EntryPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	if pageURL != "" {
+		u, err := url.Parse(pageURL)
+		if err != nil {
+			return nil, notFound()
+		}
+		offset, err = strconv.Atoi(u.Query().Get("page"))
+		if err != nil || offset < 0 {
+			return nil, notFound()
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pageSize := b.PageSize
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+
+	page := &hatena.EntryPage{}
+	for i := offset; i < len(b.entries) && i < offset+pageSize; i++ {
+		page.Entries = append(page.Entries, b.snapshot(b.entries[i]))
+	}
+	if offset+pageSize < len(b.entries) {
+		page.NextURL = fmt.Sprintf("%s?page=%d", b.CollectionURL(), offset+pageSize)
+	}
+
+	return page, nil
+}
+
+func (b *Blog) GetEntryContext(ctx context.Context, entryID string) (*article.HatenaEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, _ := b.find(entryID)
+	if e == nil {
+		return nil, notFound()
+	}
+	return b.snapshot(e), nil
+}
+
+func (b *Blog) CreateEntryContext(ctx context.Context, art *article.Article) (*article.HatenaEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.Now()
+	e := &entry{number: b.nextNumber, published: now}
+	b.nextNumber++
+	b.apply(e, art, now)
+
+	// Newest first
+	b.entries = append([]*entry{e}, b.entries...)
+
+	return b.snapshot(e), nil
+}
+
+func (b *Blog) UpdateEntryContext(ctx context.Context, entryID string, art *article.Article) (*article.HatenaEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, _ := b.find(entryID)
+	if e == nil {
+		return nil, notFound()
+	}
+	b.apply(e, art, b.Now())
+
+	return b.snapshot(e), nil
+}
+
+func (b *Blog) DeleteEntryContext(ctx context.Context, entryID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, i := b.find(entryID)
+	if e == nil {
+		return notFound()
+	}
+	b.entries = append(b.entries[:i], b.entries[i+1:]...)
+
+	return nil
+}
+
+// EditEntry changes an entry the way the web editor would, bumping its
+// edited time. Setting Path changes the custom URL.
+func (b *Blog) EditEntry(entryID string, edit func(*article.HatenaEntry)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, _ := b.find(entryID)
+	if e == nil {
+		return notFound()
+	}
+	edit(&e.data)
+	if e.data.Path != "" {
+		e.customURL = e.data.Path
+		e.data.Path = ""
+	}
+	e.data.Edited = b.Now().Format(time.RFC3339)
+
+	return nil
+}
+
+// Entries returns every entry, newest first.
+func (b *Blog) Entries() []*article.HatenaEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries := make([]*article.HatenaEntry, len(b.entries))
+	for i, e := range b.entries {
+		entries[i] = b.snapshot(e)
+	}
+	return entries
+}
+
+// apply stores art in e like a POST or PUT would. As on Hatena Blog, the
+// date is kept when art has none, and a missing custom URL falls back to
+// one derived from the date.
+func (b *Blog) apply(e *entry, art *article.Article, now time.Time) {
+	updated := now
+	if !art.DateTime.IsZero() {
+		updated = art.DateTime
+	} else if e.data.Updated != "" {
+		updated, _ = time.Parse(time.RFC3339, e.data.Updated)
+	}
+
+	if art.Path != "" {
+		e.customURL = art.Path
+	} else if e.customURL == "" {
+		e.customURL = updated.Format("2006/01/02/150405")
+	}
+
+	e.data = article.HatenaEntry{
+		Title:      art.Title,
+		Content:    art.Content,
+		Updated:    updated.Format(time.RFC3339),
+		Edited:     now.Format(time.RFC3339),
+		IsDraft:    art.Draft,
+		Categories: append([]string(nil), art.Categories...),
+	}
+}
+
+// snapshot returns a copy of e with the derived ID and links filled in, so
+// that callers cannot modify the stored entry.
+func (b *Blog) snapshot(e *entry) *article.HatenaEntry {
+	data := e.data
+	data.Categories = append([]string(nil), e.data.Categories...)
+	data.ID = fmt.Sprintf("tag:blog.hatena.ne.jp,2013:blog-%s-%s-%d", b.HatenaID, blogNumber, e.number)
+	data.EditURL = fmt.Sprintf("%s/%d", b.CollectionURL(), e.number)
+	data.URL = fmt.Sprintf("https://%s/entry/%s", b.BlogID, e.customURL)
+	data.Path = e.customURL
+	data.Published = e.published.Format(time.RFC3339)
+	return &data
+}
+
+func (b *Blog) find(entryID string) (*entry, int) {
+	for i, e := range b.entries {
+		if strconv.FormatUint(e.number, 10) == entryID {
+			return e, i
+		}
+	}
+	return nil, -1
+}
+
+func notFound() error {
+	return &hatena.APIError{StatusCode: http.StatusNotFound, Body: "404 Not Found"}
+}
@@ -0,0 +1,355 @@
+// Package hatenatest runs a fake Hatena Blog AtomPub server for end-to-end
+// tests of hatena.Client and everything built on it.
+package hatenatest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenamem"
+)
+
+// Fault is an error response the server returns instead of handling a
+// request.
+type Fault struct {
+	// Method restricts the fault to one HTTP method; empty matches any.
+	Method     string
+	StatusCode int
+	Body       string
+	// RetryAfter is sent as the Retry-After header when non-zero.
+	RetryAfter time.Duration
+	// Times is how many requests fail; zero or less means every request.
+	Times int
+	// Handled carries out the request before failing it, as when the
+	// response is lost on the way back.
+	Handled bool
+}
+
+// TooManyRequests is the response to a client that exceeds the rate limit.
+func TooManyRequests(retryAfter time.Duration) Fault {
+	return Fault{StatusCode: http.StatusTooManyRequests, Body: "Too Many Requests", RetryAfter: retryAfter, Times: 1}
+}
+
+func InternalServerError() Fault {
+	return Fault{StatusCode: http.StatusInternalServerError, Body: "Internal Server Error", Times: 1}
+}
+
+// EntryLimitExceeded is how Hatena Blog rejects posts beyond the daily
+// limit.
+func EntryLimitExceeded() Fault {
+	return Fault{Method: http.MethodPost, StatusCode: http.StatusForbidden, Body: "Entry limit was exceeded"}
+}
+
+// Server serves the collection and member endpoints of one blog, backed by
+// a hatenamem.Blog.
+type Server struct {
+	*httptest.Server
+	Blog   *hatenamem.Blog
+	APIKey string
+
+	mu       sync.Mutex
+	faults   []*Fault
+	nonces   map[string]bool
+	requests map[string]int
+}
+
+// NewServer starts a server for the blog that accepts Basic or WSSE auth
+// with hatenaID and apiKey. Point a client at it with
+// hatena.WithBaseURL(s.URL) and close it when done.
+func NewServer(hatenaID, blogID, apiKey string) *Server {
+	s := &Server{
+		Blog:     hatenamem.New(hatenaID, blogID),
+		APIKey:   apiKey,
+		nonces:   make(map[string]bool),
+		requests: make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.Blog.BaseURL = s.URL
+
+	return s
+}
+
+// Fail makes the next matching requests fail with f. Faults are matched in
+// the order they were added.
+func (s *Server) Fail(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults drops every pending fault.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns how many authenticated requests with method the server
+// received, failed ones included.
+func (s *Server) Requests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[method]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[r.Method]++
+	s.mu.Unlock()
+
+	if f := s.takeFault(r.Method); f != nil {
+		if f.Handled {
+			s.route(httptest.NewRecorder(), r)
+		}
+		if f.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((f.RetryAfter+time.Second-1)/time.Second)))
+		}
+		http.Error(w, f.Body, f.StatusCode)
+		return
+	}
+
+	s.route(w, r)
+}
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	collection := "/" + s.Blog.HatenaID + "/" + s.Blog.BlogID + "/atom/entry"
+	switch {
+	case r.URL.Path == collection:
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w, r)
+		case http.MethodPost:
+			s.create(w, r)
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	case strings.HasPrefix(r.URL.Path, collection+"/"):
+		entryID := strings.TrimPrefix(r.URL.Path, collection+"/")
+		switch r.Method {
+		case http.MethodGet:
+			s.get(w, r, entryID)
+		case http.MethodPut:
+			s.update(w, r, entryID)
+		case http.MethodDelete:
+			s.delete(w, r, entryID)
+		default:
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	if header := r.Header.Get("X-WSSE"); header != "" {
+		return s.checkWSSE(header)
+	}
+	return r.Header.Get("Authorization") == hatena.BasicAuth(s.Blog.HatenaID, s.APIKey)
+}
+
+var wsseField = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// checkWSSE verifies a UsernameToken and rejects nonces that were seen
+// before or tokens created too long ago, as a replay would be.
+func (s *Server) checkWSSE(header string) bool {
+	if !strings.HasPrefix(header, "UsernameToken ") {
+		return false
+	}
+	fields := make(map[string]string)
+	for _, match := range wsseField.FindAllStringSubmatch(header, -1) {
+		fields[match[1]] = match[2]
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(fields["Nonce"])
+	if err != nil || len(nonce) == 0 {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339, fields["Created"])
+	if err != nil || time.Since(created).Abs() > 5*time.Minute {
+		return false
+	}
+	if fields["Username"] != s.Blog.HatenaID || fields["PasswordDigest"] != hatena.WSSEDigest(nonce, fields["Created"], s.APIKey) {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nonces[fields["Nonce"]] {
+		return false
+	}
+	s.nonces[fields["Nonce"]] = true
+	return true
+}
+
+func (s *Server) takeFault(method string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, f := range s.faults {
+		if f.Method != "" && f.Method != method {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+
+	return nil
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	pageURL := ""
+	if r.URL.RawQuery != "" {
+		pageURL = s.Blog.CollectionURL() + "?" + r.URL.RawQuery
+	}
+
+	page, err := s.Blog.ListEntriesContext(r.Context(), pageURL)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	feed := hatena.AtomFeed{Xmlns: "http://www.w3.org/2005/Atom"}
+	for _, entry := range page.Entries {
+		feed.Entry = append(feed.Entry, *hatena.EntryToAtom(entry))
+	}
+	feed.Link = append(feed.Link, hatena.Link{Rel: "first", Href: s.Blog.CollectionURL()})
+	if page.NextURL != "" {
+		feed.Link = append(feed.Link, hatena.Link{Rel: "next", Href: page.NextURL})
+	}
+
+	writeXML(w, http.StatusOK, feed)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, entryID string) {
+	entry, err := s.Blog.GetEntryContext(r.Context(), entryID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeXML(w, http.StatusOK, hatena.EntryToAtom(entry))
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request) {
+	art, err := readArticle(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.Blog.CreateEntryContext(r.Context(), art)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeXML(w, http.StatusCreated, hatena.EntryToAtom(entry))
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, entryID string) {
+	art, err := readArticle(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := s.Blog.UpdateEntryContext(r.Context(), entryID, art)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeXML(w, http.StatusOK, hatena.EntryToAtom(entry))
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request, entryID string) {
+	if err := s.Blog.DeleteEntryContext(r.Context(), entryID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// readArticle decodes a posted entry into the fields hatena.Client sends.
+func readArticle(r *http.Request) (*article.Article, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry hatena.AtomEntry
+	if err := xml.Unmarshal(body, &entry); err != nil {
+		return nil, err
+	}
+
+	art := &article.Article{
+		Title:   entry.Title,
+		Content: entry.Content.Text,
+		Path:    entry.CustomURL,
+		Draft:   entry.Control != nil && entry.Control.Draft == "yes",
+	}
+	for _, category := range entry.Category {
+		art.Categories = append(art.Categories, category.Term)
+	}
+	if entry.Updated != "" {
+		art.DateTime, err = time.Parse(time.RFC3339, entry.Updated)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return art, nil
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *hatena.APIError
+	if errors.As(err, &apiErr) {
+		http.Error(w, apiErr.Body, apiErr.StatusCode)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
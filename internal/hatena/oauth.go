@@ -0,0 +1,222 @@
+package hatena
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hatena's OAuth 1.0a endpoints.
+const (
+	OAuthInitiateURL  = "https://www.hatena.com/oauth/initiate"
+	OAuthAuthorizeURL = "https://www.hatena.ne.jp/oauth/authorize"
+	OAuthTokenURL     = "https://www.hatena.com/oauth/token"
+
+	// DefaultOAuthScope is what reading and writing drafts and entries
+	// through AtomPub needs.
+	DefaultOAuthScope = "read_public,write_public,read_private,write_private"
+)
+
+// OAuth1 signs requests with OAuth 1.0a HMAC-SHA1 on behalf of the user who
+// granted the access token.
+type OAuth1 struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	Token          string
+	TokenSecret    string
+}
+
+func (a *OAuth1) Authenticate(req *http.Request) error {
+	return a.sign(req, nil, nil)
+}
+
+// sign sets the Authorization header of req. extra are additional oauth_
+// parameters and form the form-encoded body, both of which are covered by
+// the signature.
+func (a *OAuth1) sign(req *http.Request, extra map[string]string, form url.Values) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate OAuth nonce: %w", err)
+	}
+
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     a.ConsumerKey,
+		"oauth_nonce":            hex.EncodeToString(nonce),
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(time.Now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if a.Token != "" {
+		oauthParams["oauth_token"] = a.Token
+	}
+	for key, value := range extra {
+		oauthParams[key] = value
+	}
+
+	oauthParams["oauth_signature"] = a.signature(req.Method, req.URL, oauthParams, form)
+
+	keys := make([]string, 0, len(oauthParams))
+	for key := range oauthParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf(`%s="%s"`, oauthEscape(key), oauthEscape(oauthParams[key]))
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(parts, ", "))
+
+	return nil
+}
+
+// signature computes oauth_signature as described in RFC 5849 section 3.4.
+func (a *OAuth1) signature(method string, u *url.URL, oauthParams map[string]string, form url.Values) string {
+	key := oauthEscape(a.ConsumerSecret) + "&" + oauthEscape(a.TokenSecret)
+
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(signatureBase(method, u, oauthParams, form)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signatureBase builds the signature base string of RFC 5849 section 3.4.1.
+func signatureBase(method string, u *url.URL, oauthParams map[string]string, form url.Values) string {
+	type param struct{ key, value string }
+	var params []param
+	add := func(key, value string) {
+		params = append(params, param{oauthEscape(key), oauthEscape(value)})
+	}
+	for key, value := range oauthParams {
+		add(key, value)
+	}
+	for key, values := range u.Query() {
+		for _, value := range values {
+			add(key, value)
+		}
+	}
+	for key, values := range form {
+		for _, value := range values {
+			add(key, value)
+		}
+	}
+
+	// Sorting by name and then by value, rather than "name=value" as a
+	// whole, puts "a" before "a-b" (section 3.4.1.3.2)
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].value < params[j].value
+	})
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.key + "=" + p.value
+	}
+
+	baseURL := *u
+	baseURL.RawQuery = ""
+	baseURL.Fragment = ""
+	baseURL.Scheme = strings.ToLower(baseURL.Scheme)
+	baseURL.Host = strings.ToLower(baseURL.Host)
+
+	return strings.ToUpper(method) + "&" + oauthEscape(baseURL.String()) + "&" + oauthEscape(strings.Join(pairs, "&"))
+}
+
+// oauthEscape percent-encodes everything but the RFC 3986 unreserved
+// characters, as OAuth requires.
+func oauthEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// OAuthCredentials is a token and secret pair returned by the OAuth
+// endpoints. UserName is only set for access tokens.
+type OAuthCredentials struct {
+	Token       string
+	TokenSecret string
+	UserName    string
+}
+
+// RequestTemporaryCredentials starts the out-of-band authorization flow.
+// The user approves the returned token at OAuthAuthorizationURL.
+func RequestTemporaryCredentials(ctx context.Context, consumerKey, consumerSecret, scope string) (*OAuthCredentials, error) {
+	consumer := &OAuth1{ConsumerKey: consumerKey, ConsumerSecret: consumerSecret}
+	return consumer.requestToken(ctx, OAuthInitiateURL, map[string]string{"oauth_callback": "oob"}, url.Values{"scope": {scope}})
+}
+
+// OAuthAuthorizationURL is where the user approves temporary credentials
+// and is shown the verifier.
+func OAuthAuthorizationURL(temporary *OAuthCredentials) string {
+	return OAuthAuthorizeURL + "?oauth_token=" + url.QueryEscape(temporary.Token)
+}
+
+// RequestAccessToken exchanges approved temporary credentials and the
+// verifier shown to the user for an access token.
+func RequestAccessToken(ctx context.Context, consumerKey, consumerSecret string, temporary *OAuthCredentials, verifier string) (*OAuthCredentials, error) {
+	consumer := &OAuth1{
+		ConsumerKey:    consumerKey,
+		ConsumerSecret: consumerSecret,
+		Token:          temporary.Token,
+		TokenSecret:    temporary.TokenSecret,
+	}
+	return consumer.requestToken(ctx, OAuthTokenURL, map[string]string{"oauth_verifier": verifier}, nil)
+}
+
+func (a *OAuth1) requestToken(ctx context.Context, endpoint string, extra map[string]string, form url.Values) (*OAuthCredentials, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "hatenablog-atompub-client/1.0")
+	if err := a.sign(req, extra, form); err != nil {
+		return nil, err
+	}
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OAuth response: %w", err)
+	}
+	if values.Get("oauth_token") == "" || values.Get("oauth_token_secret") == "" {
+		return nil, fmt.Errorf("OAuth response has no token")
+	}
+
+	return &OAuthCredentials{
+		Token:       values.Get("oauth_token"),
+		TokenSecret: values.Get("oauth_token_secret"),
+		UserName:    values.Get("url_name"),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package hatena
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestOAuthSignature(t *testing.T) {
+	// The example of RFC 5849 section 1.2
+	a := &OAuth1{
+		ConsumerKey:    "dpf43f3p2l4k3l03",
+		ConsumerSecret: "kd94hf93k423kf44",
+		Token:          "nnch734d00sl2jdk",
+		TokenSecret:    "pfkkdhi9sl3r4s00",
+	}
+	u, err := url.Parse("http://photos.example.net/photos?file=vacation.jpg&size=original")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauthParams := map[string]string{
+		"oauth_consumer_key":     a.ConsumerKey,
+		"oauth_token":            a.Token,
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "137131202",
+		"oauth_nonce":            "chapoH",
+	}
+
+	if got, want := a.signature("GET", u, oauthParams, nil), "MdpQcU8iPSUjWoN/UDMsK2sui9I="; got != want {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+}
+
+func TestOAuthSignatureBase(t *testing.T) {
+	tests := map[string]struct {
+		url  string
+		form url.Values
+		want string
+	}{
+		"name that prefixes another": {
+			url:  "https://Example.COM/path?a-b=1&a=2",
+			want: "a%3D2%26a-b%3D1%26oauth_nonce%3Dn",
+		},
+		"repeated name by value": {
+			url:  "https://example.com/path?c=2&c=10",
+			form: url.Values{"c": {"1"}},
+			want: "c%3D1%26c%3D10%26c%3D2%26oauth_nonce%3Dn",
+		},
+		"encoded before sorting": {
+			url:  "https://example.com/path?b%20c=1&b=2",
+			want: "b%3D2%26b%2520c%3D1%26oauth_nonce%3Dn",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			base := signatureBase("post", u, map[string]string{"oauth_nonce": "n"}, tt.form)
+			method, rest, _ := strings.Cut(base, "&")
+			baseURL, params, _ := strings.Cut(rest, "&")
+			if method != "POST" || baseURL != "https%3A%2F%2Fexample.com%2Fpath" {
+				t.Errorf("got base %s", base)
+			}
+			if params != tt.want {
+				t.Errorf("got parameters %s, want %s", params, tt.want)
+			}
+		})
+	}
+}
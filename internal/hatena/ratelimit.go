@@ -0,0 +1,91 @@
+package hatena
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRequestsPerSecond = 5
+	DefaultBurst             = 5
+)
+
+// RateLimiter is a token bucket that every request of a Client waits on.
+// It is safe for concurrent use and can be shared between clients.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows requestsPerSecond on average and bursts of up to
+// burst requests. A non-positive rate disables limiting.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done. A caller that
+// gives up waiting does not use up a token.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if err := sleepContext(ctx, l.reserve()); err != nil {
+		l.release()
+		return err
+	}
+	return nil
+}
+
+// reserve takes a token, possibly borrowing from the future, and returns how
+// long the caller has to wait until that token is actually available.
+func (l *RateLimiter) reserve() time.Duration {
+	if l == nil || l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// release gives back a token taken by reserve that was not used.
+func (l *RateLimiter) release() {
+	if l == nil || l.rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
@@ -0,0 +1,69 @@
+package hatena_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// waitAll waits n times and returns how long that took.
+func waitAll(t *testing.T, l *hatena.RateLimiter, n int) time.Duration {
+	t.Helper()
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return time.Since(start)
+}
+
+func TestRateLimiter(t *testing.T) {
+	tests := map[string]struct {
+		rate  float64
+		burst int
+		waits int
+		// the waits take at least min and less than max
+		min, max time.Duration
+	}{
+		"burst": {rate: 10, burst: 5, waits: 5, max: 50 * time.Millisecond},
+		// 10 waits beyond the burst at 100 per second
+		"steady rate":     {rate: 100, burst: 2, waits: 12, min: 90 * time.Millisecond, max: 250 * time.Millisecond},
+		"zero rate":       {rate: 0, burst: 1, waits: 1000, max: 50 * time.Millisecond},
+		"negative rate":   {rate: -1, burst: 1, waits: 1000, max: 50 * time.Millisecond},
+		"burst below one": {rate: 20, burst: 0, waits: 3, min: 90 * time.Millisecond, max: 250 * time.Millisecond},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			elapsed := waitAll(t, hatena.NewRateLimiter(tt.rate, tt.burst), tt.waits)
+			if elapsed < tt.min || elapsed >= tt.max {
+				t.Errorf("took %s, want %s to %s", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	l := hatena.NewRateLimiter(10, 1)
+	waitAll(t, l, 1)
+
+	// Each of these gives up well before its token is due in 100ms
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := l.Wait(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("got error %v", err)
+		}
+	}
+
+	// Had the cancelled waits kept their tokens, this would wait 600ms
+	if elapsed := waitAll(t, l, 1); elapsed >= 300*time.Millisecond {
+		t.Errorf("took %s after cancelled waits", elapsed)
+	}
+}
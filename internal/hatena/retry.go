@@ -0,0 +1,148 @@
+package hatena
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay, with jitter, unless the
+// server asks for a specific delay with Retry-After. Retry-After is capped at
+// MaxDelay too, so that a server cannot park the client for hours.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+// retryDelay reports whether a request that failed with err on the given
+// attempt should be tried again, and after how long.
+func (c *Client) retryDelay(ctx context.Context, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.retryPolicy.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			if apiErr.RetryAfter > 0 {
+				delay := apiErr.RetryAfter
+				if c.retryPolicy.MaxDelay > 0 && delay > c.retryPolicy.MaxDelay {
+					delay = c.retryPolicy.MaxDelay
+				}
+				return delay, true
+			}
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+		return c.backoff(attempt), true
+	}
+
+	// Errors building or signing the request would only happen again
+	if !isTransportError(err) {
+		return 0, false
+	}
+	return c.backoff(attempt), true
+}
+
+// isTransportError reports whether err happened on the way to or from the
+// server, such as a refused or reset connection or a timeout.
+func isTransportError(err error) bool {
+	// http.Client wraps every error in *url.Error, which is a net.Error
+	// whatever it wraps
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// backoff returns the delay before the retry following attempt, picked at
+// random from the upper half of the exponential delay.
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.retryPolicy.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > c.retryPolicy.MaxDelay {
+		delay = c.retryPolicy.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func (c *Client) logRetry(method, url string, attempt int, delay time.Duration, err error) {
+	c.logger.Printf("Retrying %s %s in %s (attempt %d/%d failed: %v)", method, url, delay.Round(time.Millisecond), attempt, c.retryPolicy.MaxAttempts, err)
+}
+
+// isRejected reports whether the server turned a request away before
+// acting on it, which makes even a POST safe to repeat.
+func isRejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(t); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// sleepContext waits for d, returning early with the context's error if ctx
+// is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package hatena_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenatest"
+)
+
+// failingAuth fails to sign every request.
+type failingAuth struct{}
+
+func (failingAuth) Authenticate(req *http.Request) error {
+	return errors.New("no signing key")
+}
+
+func TestRetry(t *testing.T) {
+	tests := map[string]struct {
+		faults []hatenatest.Fault
+		policy hatena.RetryPolicy
+		// down closes the server before the request
+		down       bool
+		opts       []hatena.Option
+		wantStatus int
+		// wantErr is for errors without a status
+		wantErr bool
+		// wantLog is a retry the client logs
+		wantLog string
+	}{
+		"429 waits for Retry-After": {
+			faults:  []hatenatest.Fault{hatenatest.TooManyRequests(time.Second)},
+			policy:  hatena.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second},
+			wantLog: "in 1s",
+		},
+		"Retry-After is capped": {
+			faults:  []hatenatest.Fault{hatenatest.TooManyRequests(time.Hour)},
+			policy:  fastRetries,
+			wantLog: "in 20ms",
+		},
+		"500 backs off": {
+			faults:  []hatenatest.Fault{hatenatest.InternalServerError(), hatenatest.InternalServerError()},
+			policy:  fastRetries,
+			wantLog: "attempt 2/3 failed",
+		},
+		"500 until out of attempts": {
+			faults:     []hatenatest.Fault{{StatusCode: http.StatusInternalServerError, Body: "Internal Server Error"}},
+			policy:     fastRetries,
+			wantStatus: http.StatusInternalServerError,
+			wantLog:    "attempt 2/3 failed",
+		},
+		"403 is not retried": {
+			faults:     []hatenatest.Fault{{StatusCode: http.StatusForbidden, Body: "Forbidden", Times: 1}},
+			policy:     fastRetries,
+			wantStatus: http.StatusForbidden,
+		},
+		"refused connection backs off": {
+			down:    true,
+			policy:  fastRetries,
+			wantErr: true,
+			wantLog: "attempt 2/3 failed",
+		},
+		"signing error is not retried": {
+			opts:    []hatena.Option{hatena.WithAuthenticator(failingAuth{})},
+			policy:  fastRetries,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+			for _, f := range tt.faults {
+				srv.Fail(f)
+			}
+			if tt.down {
+				srv.Close()
+			}
+			var logs bytes.Buffer
+			client := newClient(t, srv, "", &logs, append(tt.opts, hatena.WithRetryPolicy(tt.policy))...)
+
+			_, err := client.ListEntriesContext(context.Background(), "")
+			if got := statusOf(err); got != tt.wantStatus || (tt.wantStatus == 0 && (err != nil) != tt.wantErr) {
+				t.Errorf("got %v, want status %d", err, tt.wantStatus)
+			}
+			if tt.wantLog == "" && logs.Len() > 0 {
+				t.Errorf("retried:\n%s", logs.String())
+			}
+			if !strings.Contains(logs.String(), tt.wantLog) {
+				t.Errorf("log does not contain %q:\n%s", tt.wantLog, logs.String())
+			}
+		})
+	}
+}
+
+func TestCreateEntryRetry(t *testing.T) {
+	serverError := hatenatest.Fault{Method: http.MethodPost, StatusCode: http.StatusBadGateway, Body: "Bad Gateway", Times: 1}
+	lost := serverError
+	lost.Handled = true
+
+	tests := map[string]struct {
+		// existing entries are posted before the fault is set up
+		existing    []string
+		faults      []hatenatest.Fault
+		wantErr     bool
+		wantEntries int
+	}{
+		"not created": {
+			faults:      []hatenatest.Fault{serverError},
+			wantEntries: 1,
+		},
+		"created": {
+			faults:      []hatenatest.Fault{lost},
+			wantEntries: 1,
+		},
+		"created next to an older entry of the same title": {
+			existing:    []string{"Hello"},
+			faults:      []hatenatest.Fault{lost},
+			wantEntries: 2,
+		},
+		"unconfirmed without a listing before posting": {
+			faults: []hatenatest.Fault{
+				{Method: http.MethodGet, StatusCode: http.StatusInternalServerError, Body: "Internal Server Error", Times: 3},
+				serverError,
+			},
+			wantErr: true,
+		},
+		"rejected": {
+			faults:  []hatenatest.Fault{{Method: http.MethodPost, StatusCode: http.StatusBadRequest, Body: "Bad Request", Times: 1}},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newServer(t)
+			for _, title := range tt.existing {
+				if _, err := srv.Blog.CreateEntryContext(context.Background(), &article.Article{Title: title, Content: "old"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, f := range tt.faults {
+				srv.Fail(f)
+			}
+			var logs bytes.Buffer
+			client := newClient(t, srv, "", &logs)
+
+			entry, err := client.CreateEntryContext(context.Background(), &article.Article{Title: "Hello", Content: "body"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t\n%s", err, tt.wantErr, logs.String())
+			}
+			if err == nil && entry.Content != "body" {
+				t.Errorf("got entry %+v", entry)
+			}
+			if got := len(srv.Blog.Entries()); got != tt.wantEntries {
+				t.Errorf("blog has %d entries, want %d\n%s", got, tt.wantEntries, logs.String())
+			}
+		})
+	}
+}
+
+func TestCreateEntryListsEntriesOnce(t *testing.T) {
+	srv := newServer(t)
+	var logs bytes.Buffer
+	client := newClient(t, srv, "", &logs)
+
+	for i, title := range []string{"First", "Second", "Third"} {
+		if i == 2 {
+			// The entries posted before are known without listing again
+			srv.Fail(hatenatest.Fault{Method: http.MethodPost, StatusCode: http.StatusBadGateway, Body: "Bad Gateway", Times: 1, Handled: true})
+		}
+		if _, err := client.CreateEntryContext(context.Background(), &article.Article{Title: title, Content: "body"}); err != nil {
+			t.Fatalf("%s: %v\n%s", title, err, logs.String())
+		}
+	}
+
+	// Once before the first post and once to confirm the lost response
+	if lists := srv.Requests(http.MethodGet); lists != 2 {
+		t.Errorf("listed entries %d times, want 2", lists)
+	}
+	if got := len(srv.Blog.Entries()); got != 3 {
+		t.Errorf("blog has %d entries, want 3\n%s", got, logs.String())
+	}
+}
@@ -0,0 +1,171 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	DirName  = ".hatenasync"
+	FileName = "state.json"
+
+	version = 1
+)
+
+// Entry is what was known about an article and its remote entry when it was
+// last synced.
+type Entry struct {
+	// Blog is the blog the entry belongs to; empty in states written
+	// before articles could target other blogs.
+	Blog          string `json:"blog,omitempty"`
+	UUID          string `json:"uuid"`
+	EntryID       string `json:"entry_id"`
+	EditURL       string `json:"edit_url"`
+	URL           string `json:"url,omitempty"`
+	ContentHash   string `json:"content_hash"`
+	RemoteUpdated string `json:"remote_updated"`
+	// RemoteHash is the hash of the remote entry, which tells an edit made
+	// within the same second as the sync; empty when it is not known.
+	RemoteHash string `json:"remote_hash,omitempty"`
+}
+
+// State maps article files, relative to the article directory, to their
+// last synced Entry. It is safe for concurrent use.
+type State struct {
+	dir     string
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+type stateFile struct {
+	Version  int              `json:"version"`
+	Articles map[string]Entry `json:"articles"`
+}
+
+// Path returns the location of the state file for an article directory.
+func Path(dir string) string {
+	return filepath.Join(dir, DirName, FileName)
+}
+
+// Load reads the state of the article directory dir. A missing state file
+// yields an empty State.
+func Load(dir string) (*State, error) {
+	s := &State{dir: dir, entries: make(map[string]Entry)}
+
+	data, err := os.ReadFile(Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", Path(dir), err)
+	}
+	if file.Version != version {
+		return nil, fmt.Errorf("unsupported state file version %d in %s", file.Version, Path(dir))
+	}
+	for path, entry := range file.Articles {
+		s.entries[path] = entry
+	}
+
+	return s, nil
+}
+
+// Save writes the state back to the article directory.
+func (s *State) Save() error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(stateFile{Version: version, Articles: s.entries}, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	path := Path(s.dir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	// Write through a temporary file so that an interrupted run never
+	// leaves a truncated state behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the entry recorded for an article file.
+func (s *State) Get(filePath string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[s.key(filePath)]
+	return entry, ok
+}
+
+func (s *State) Set(filePath string, entry Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[s.key(filePath)] = entry
+}
+
+func (s *State) Delete(filePath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, s.key(filePath))
+}
+
+// Rename moves the entry of oldPath to newPath.
+func (s *State) Rename(oldPath, newPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	oldKey := s.key(oldPath)
+	if entry, ok := s.entries[oldKey]; ok {
+		delete(s.entries, oldKey)
+		s.entries[s.key(newPath)] = entry
+	}
+}
+
+// Files returns the recorded article files in sorted order.
+func (s *State) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	files := make([]string, len(keys))
+	for i, key := range keys {
+		files[i] = filepath.Join(s.dir, filepath.FromSlash(key))
+	}
+	return files
+}
+
+// key makes filePath relative to the article directory so that the state
+// stays valid wherever the directory is checked out.
+func (s *State) key(filePath string) string {
+	rel, err := filepath.Rel(s.dir, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
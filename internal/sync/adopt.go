@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// Adoption policies for articles without a UUID that match an existing
+// remote entry.
+const (
+	// AdoptAsk links after confirmation, and skips the article when there
+	// is no way to ask.
+	AdoptAsk = "ask"
+	// AdoptYes links without asking.
+	AdoptYes = "yes"
+	// AdoptNo always creates a new entry, as if nothing matched.
+	AdoptNo = "no"
+)
+
+// adoption is a remote entry an article without a UUID appears to be.
+type adoption struct {
+	entry *article.HatenaEntry
+	// by is what matched, "path" or "title"
+	by string
+}
+
+// findAdoptions matches articles without a UUID to remote entries that no
+// article is linked to, by custom URL or, failing that, by title. A title
+// only matches when exactly one such entry has it. Each entry is matched
+// at most once, to the first article in order.
+func findAdoptions(localArticles []*article.Article, remoteEntries []*article.HatenaEntry) map[*article.Article]adoption {
+	claimed := make(map[string]bool)
+	for _, art := range localArticles {
+		if art.UUID != "" {
+			claimed[art.UUID] = true
+		}
+	}
+
+	var unclaimed []*article.HatenaEntry
+	for _, entry := range remoteEntries {
+		uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
+		if uuid == "" || claimed[uuid] || isRedirectStub(entry) {
+			continue
+		}
+		unclaimed = append(unclaimed, entry)
+	}
+
+	adoptions := make(map[*article.Article]adoption)
+	adopted := make(map[*article.HatenaEntry]bool)
+	for _, art := range localArticles {
+		if art.UUID != "" {
+			continue
+		}
+
+		var byTitle []*article.HatenaEntry
+		var found *adoption
+		for _, entry := range unclaimed {
+			if adopted[entry] {
+				continue
+			}
+			if art.Path != "" && entry.Path == art.Path {
+				found = &adoption{entry: entry, by: "path"}
+				break
+			}
+			if entry.Title == art.Title {
+				byTitle = append(byTitle, entry)
+			}
+		}
+		if found == nil && len(byTitle) == 1 {
+			found = &adoption{entry: byTitle[0], by: "title"}
+		}
+
+		if found != nil {
+			adoptions[art] = *found
+			adopted[found.entry] = true
+		}
+	}
+
+	return adoptions
+}
+
+// confirmAdoptions asks about every planned adoption under AdoptAsk.
+// Declined adoptions create the article after all. Without a way to ask the
+// article is skipped, so that neither a duplicate nor an unwanted link is
+// made.
+func (s *Syncer) confirmAdoptions(sets []*blogSet) {
+	if s.adopt != AdoptAsk {
+		return
+	}
+
+	for _, set := range sets {
+		for i := range set.actions {
+			action := &set.actions[i]
+			if action.Type != "adopt" {
+				continue
+			}
+
+			var link bool
+			var err error
+			if s.confirmAdopt == nil {
+				err = errors.New("cannot ask")
+			} else {
+				link, err = s.confirmAdopt(action.Article, action.RemoteEntry)
+			}
+
+			switch {
+			case err != nil:
+				action.Type = "skip"
+				action.Reason = fmt.Sprintf("%s; not linked without confirmation (use -adopt yes or -adopt no)", action.Reason)
+			case !link:
+				action.Type = "create"
+				action.Reason = fmt.Sprintf("New article (declined linking to %s)", action.RemoteEntry.URL)
+				action.RemoteEntry = nil
+				action.identify(set.blog)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+// unlink forgets that a.md was synced, as in a fresh clone made before its
+// UUID was written back, and rewrites it as given.
+func (f *fixture) unlink(content string) {
+	f.t.Helper()
+
+	if err := os.RemoveAll(filepath.Join(f.dir, state.DirName)); err != nil {
+		f.t.Fatal(err)
+	}
+	f.write("a.md", content)
+}
+
+func TestSyncAdoption(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"by title": {
+			change: func(f *fixture) { f.unlink("---\ntitle: A\n---\nbody\n") },
+			opts:   Options{Adopt: AdoptYes},
+			want:   counts{Adopted: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 1 {
+					t.Errorf("blog has %d entries, want 1", len(f.blog.Entries()))
+				}
+				if !strings.Contains(f.read("a.md"), "uuid:") {
+					t.Errorf("a.md was not linked:\n%s", f.read("a.md"))
+				}
+			},
+		},
+		"by path": {
+			change: func(f *fixture) {
+				f.unlink("---\ntitle: Renamed\npath: " + f.blog.Entries()[0].Path + "\n---\nbody\n")
+			},
+			opts: Options{Adopt: AdoptYes},
+			want: counts{Adopted: 1},
+			check: func(t *testing.T, f *fixture) {
+				// The file wins, as for any article without a recorded sync
+				if entries := f.blog.Entries(); len(entries) != 1 || entries[0].Title != "Renamed" {
+					t.Errorf("got entries %+v", entries)
+				}
+			},
+		},
+		"title shared by two entries": {
+			change: func(f *fixture) {
+				if _, err := f.blog.CreateEntryContext(context.Background(), &article.Article{Title: "A", Content: "other"}); err != nil {
+					f.t.Fatal(err)
+				}
+				f.unlink("---\ntitle: A\n---\nbody\n")
+			},
+			opts: Options{Adopt: AdoptYes},
+			want: counts{Created: 1},
+		},
+		"not asked to": {
+			change: func(f *fixture) { f.unlink("---\ntitle: A\n---\nbody\n") },
+			want:   counts{Created: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 2 {
+					t.Errorf("blog has %d entries, want 2", len(f.blog.Entries()))
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,192 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	gosync "sync"
+	"time"
+)
+
+// applyActions runs the planned actions of a blog on a pool of
+// s.concurrency workers.
+// Log output is written in plan order regardless of completion order. An
+// error from any action, such as the daily posting limit, or the end of ctx
+// stops handing out further actions; the error is returned once in-flight
+// actions have finished. Actions already started run to completion, see
+// detach.
+func (s *Syncer) applyActions(parent context.Context, set *blogSet, result *SyncResult) error {
+	actions := set.actions
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	workers := s.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	output := newOrderedLog(log.Writer(), s.reporter, len(actions))
+	jobs := make(chan int)
+	var abortErr error
+	var abortOnce gosync.Once
+	var wg gosync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// The dispatcher may hand out one more action as the run
+				// stops, since select picks among ready cases at random
+				if ctx.Err() != nil {
+					output.skip(i)
+					continue
+				}
+				var buf bytes.Buffer
+				logger := log.New(&buf, log.Prefix(), log.Flags())
+				report := newActionReport(&actions[i])
+				actionCtx, cancelAction := detach(ctx)
+				err := s.applyAction(actionCtx, set, actions[i], result, logger, &report)
+				cancelAction()
+				if err != nil {
+					abortOnce.Do(func() {
+						abortErr = err
+						cancel()
+					})
+				}
+				output.finish(i, buf.Bytes(), report)
+			}
+		}()
+	}
+
+dispatch:
+	for i := range actions {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if abortErr != nil {
+		return abortErr
+	}
+	return parent.Err()
+}
+
+// inFlightGrace is how long a started action may go on once the run is
+// interrupted.
+const inFlightGrace = 30 * time.Second
+
+// detach returns a context for an action that has started. It outlives ctx by
+// up to inFlightGrace, so that an interrupt does not abandon a request the
+// blog may already have carried out, which would leave, say, a created entry
+// without its UUID written back to the file.
+func detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+			select {
+			case <-time.After(inFlightGrace):
+				cancel()
+			case <-detached.Done():
+			}
+		case <-detached.Done():
+		}
+	}()
+	return detached, cancel
+}
+
+func (r *SyncResult) addError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Errors = append(r.Errors, err)
+}
+
+func (r *SyncResult) count(actionType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch actionType {
+	case "create":
+		r.Created++
+	case "update":
+		r.Updated++
+	case "pull":
+		r.Pulled++
+	case "adopt":
+		r.Adopted++
+	case "skip":
+		r.Skipped++
+	case "delete":
+		r.Deleted++
+	}
+}
+
+// orderedLog writes the output and report of each action once every action
+// before it has finished, so that concurrent runs print like sequential
+// ones. An action that is skipped without being started writes nothing.
+type orderedLog struct {
+	mu       gosync.Mutex
+	out      io.Writer
+	reporter Reporter
+	outputs  [][]byte
+	reports  []ActionReport
+	started  []bool
+	done     []bool
+	next     int
+}
+
+func newOrderedLog(out io.Writer, reporter Reporter, n int) *orderedLog {
+	return &orderedLog{
+		out:      out,
+		reporter: reporter,
+		outputs:  make([][]byte, n),
+		reports:  make([]ActionReport, n),
+		started:  make([]bool, n),
+		done:     make([]bool, n),
+	}
+}
+
+func (o *orderedLog) finish(i int, output []byte, report ActionReport) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.outputs[i] = output
+	o.reports[i] = report
+	o.started[i] = true
+	o.done[i] = true
+	o.flush()
+}
+
+// skip marks action i done without output, so that the actions after it
+// are still written.
+func (o *orderedLog) skip(i int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.done[i] = true
+	o.flush()
+}
+
+func (o *orderedLog) flush() {
+	for o.next < len(o.done) && o.done[o.next] {
+		if o.started[o.next] {
+			o.out.Write(o.outputs[o.next])
+			if o.reporter != nil {
+				o.reporter.Action(o.reports[o.next])
+			}
+		}
+		o.outputs[o.next] = nil
+		o.next++
+	}
+}
@@ -0,0 +1,160 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	gosync "sync"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenamem"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenatest"
+)
+
+func TestDailyLimitStopsConcurrentPosts(t *testing.T) {
+	const articles = 12
+
+	tests := map[string]struct {
+		// synced articles are created before the limit is reached and then
+		// moved to a new custom URL
+		synced bool
+		opts   Options
+		// wantDone is whether each worker gets an action done before its
+		// post is rejected
+		wantDone bool
+	}{
+		"creates": {},
+		"redirect stubs": {
+			synced:   true,
+			opts:     Options{RedirectStubs: true},
+			wantDone: true,
+		},
+	}
+
+	for name, tt := range tests {
+		for _, concurrency := range []int{1, 4} {
+			t.Run(fmt.Sprintf("%s with concurrency %d", name, concurrency), func(t *testing.T) {
+				srv := hatenatest.NewServer("me", testBlog, "secret")
+				defer srv.Close()
+
+				f := newFixture(t)
+				f.blog = srv.Blog
+				for i := 0; i < articles; i++ {
+					f.write(fmt.Sprintf("%02d.md", i), fmt.Sprintf("---\ntitle: Post %d\npath: old-%d\n---\nbody\n", i, i))
+				}
+
+				cfg := &config.Config{HatenaID: "me", BlogID: testBlog, APIKey: "secret"}
+				client, err := hatena.NewClient(cfg,
+					hatena.WithBaseURL(srv.URL),
+					hatena.WithRateLimiter(hatena.NewRateLimiter(0, 1)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts := tt.opts
+				opts.StateDir = f.dir
+				opts.Blog = testBlog
+				opts.Concurrency = concurrency
+
+				if tt.synced {
+					f.mustSync(Options{})
+					for i := 0; i < articles; i++ {
+						f.replace(fmt.Sprintf("%02d.md", i), "path: old-", "path: new-")
+					}
+				}
+				srv.Fail(hatenatest.EntryLimitExceeded())
+				posted := srv.Requests(http.MethodPost)
+
+				result, err := NewSyncerWithOptions(client, opts).SyncArticles(f.articles())
+				if err == nil || !strings.Contains(err.Error(), "daily posting limit exceeded") {
+					t.Fatalf("got error %v", err)
+				}
+				if len(result.Errors) != 0 {
+					t.Errorf("errors %v", result.Errors)
+				}
+				if done := result.Created + result.Updated; tt.wantDone != (done > 0) || done > concurrency {
+					t.Errorf("created %d and updated %d", result.Created, result.Updated)
+				}
+
+				// Every worker stops after its first rejected post
+				if posts := srv.Requests(http.MethodPost) - posted; posts < 1 || posts > concurrency {
+					t.Errorf("sent %d posts, want 1 to %d", posts, concurrency)
+				}
+				if want := map[bool]int{false: 0, true: articles}[tt.synced]; len(srv.Blog.Entries()) != want {
+					t.Errorf("blog has %d entries, want %d", len(srv.Blog.Entries()), want)
+				}
+			})
+		}
+	}
+}
+
+// cancelingBlog cancels the run once it has created after entries.
+type cancelingBlog struct {
+	*hatenamem.Blog
+	after   int
+	cancel  context.CancelFunc
+	mu      gosync.Mutex
+	created int
+}
+
+func (b *cancelingBlog) CreateEntryContext(ctx context.Context, art *article.Article) (*article.HatenaEntry, error) {
+	entry, err := b.Blog.CreateEntryContext(ctx, art)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.created++; b.created == b.after {
+		b.cancel()
+	}
+	return entry, err
+}
+
+type recordingReporter struct {
+	reports []ActionReport
+}
+
+func (r *recordingReporter) Action(report ActionReport) {
+	r.reports = append(r.reports, report)
+}
+
+func TestCancelReportsCompletedActions(t *testing.T) {
+	const articles = 40
+
+	// Which actions are in flight as the run stops depends on scheduling,
+	// so cancel at several points
+	for after := 1; after <= articles; after += 3 {
+		t.Run(fmt.Sprintf("after %d creates", after), func(t *testing.T) {
+			f := newFixture(t)
+			for i := 0; i < articles; i++ {
+				f.write(fmt.Sprintf("%02d.md", i), fmt.Sprintf("---\ntitle: Post %d\n---\nbody\n", i))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			blog := &cancelingBlog{Blog: f.blog, after: after, cancel: cancel}
+			reporter := &recordingReporter{}
+
+			s := NewSyncerWithOptions(blog, Options{StateDir: f.dir, Blog: testBlog, Concurrency: 4, Reporter: reporter})
+			result, err := s.SyncArticlesContext(ctx, f.articles())
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("got error %v", err)
+			}
+
+			created := len(f.blog.Entries())
+			if result.Created != created {
+				t.Errorf("created %d, blog has %d entries", result.Created, created)
+			}
+			if len(reporter.reports) != created {
+				t.Errorf("reported %d actions, want %d", len(reporter.reports), created)
+			}
+			for _, report := range reporter.reports {
+				if report.Type != "create" || report.Error != "" {
+					t.Errorf("reported %+v", report)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package sync
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+// BackupDirName is the directory under the state directory that holds a
+// timestamped backup directory for every run that deletes entries.
+const BackupDirName = "backup"
+
+// backupInfo is the frontmatter a Markdown backup has besides the article
+// keys, for what an article file cannot express.
+type backupInfo struct {
+	Blog      string `yaml:"blog"`
+	URL       string `yaml:"url"`
+	Published string `yaml:"published,omitempty"`
+	Edited    string `yaml:"edited,omitempty"`
+}
+
+// backupEntry saves an entry of blog as <uuid>.md and <uuid>.xml before it is
+// deleted. The backup directory of the run is created on first use:
+// <article dir>/.hatenasync/backup/<time>/<blog>.
+func (s *Syncer) backupEntry(blog string, entry *article.HatenaEntry) error {
+	uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
+	if uuid == "" {
+		return fmt.Errorf("failed to extract UUID from entry ID: %s", entry.ID)
+	}
+
+	root, err := s.backupRoot()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(root, blog)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	data, err := xml.MarshalIndent(hatena.EntryToAtom(entry), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal backup of %s: %w", entry.URL, err)
+	}
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(filepath.Join(dir, uuid+".xml"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write backup of %s: %w", entry.URL, err)
+	}
+
+	art, err := articleFromEntry(entry)
+	if err != nil {
+		return err
+	}
+	art.FilePath = filepath.Join(dir, uuid+".md")
+	info := backupInfo{Blog: blog, URL: entry.URL, Published: entry.Published, Edited: entry.Edited}
+	if err := saveWithFrontmatter(art, info); err != nil {
+		return fmt.Errorf("failed to write backup of %s: %w", entry.URL, err)
+	}
+
+	return nil
+}
+
+// backupRoot returns the backup directory of this run, creating it once.
+func (s *Syncer) backupRoot() (string, error) {
+	s.backupMu.Lock()
+	defer s.backupMu.Unlock()
+
+	if s.backupDir != "" {
+		return s.backupDir, nil
+	}
+
+	dir := filepath.Join(s.stateDir, state.DirName, BackupDirName, time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	log.Printf("Backing up deleted entries to %s", dir)
+	s.backupDir = dir
+	return dir, nil
+}
+
+// saveWithFrontmatter saves art into a new file whose frontmatter also has
+// the keys of extra. article.Save keeps keys it does not know about.
+func saveWithFrontmatter(art *article.Article, extra any) error {
+	data, err := yaml.Marshal(extra)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(art.FilePath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(art.FilePath, []byte("---\n"+string(data)+"---\n"), 0644); err != nil {
+		return err
+	}
+	return article.Save(art)
+}
+
+// backupFile is an entry saved by backupEntry.
+type backupFile struct {
+	blog string
+	uuid string
+	path string
+}
+
+// findBackups lists the XML backups under dir, which is a backup directory
+// of one run or of one of its blogs. With uuids given, only those are listed.
+func findBackups(dir string, uuids []string) ([]backupFile, error) {
+	wanted := make(map[string]bool)
+	for _, uuid := range uuids {
+		wanted[uuid] = true
+	}
+
+	var backups []backupFile
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".xml" {
+			return nil
+		}
+
+		uuid := strings.TrimSuffix(d.Name(), ".xml")
+		if len(wanted) > 0 && !wanted[uuid] {
+			return nil
+		}
+		backups = append(backups, backupFile{blog: filepath.Base(filepath.Dir(path)), uuid: uuid, path: path})
+		delete(wanted, uuid)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	for uuid := range wanted {
+		return nil, fmt.Errorf("no backup of %s in %s", uuid, dir)
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups in %s", dir)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].blog != backups[j].blog {
+			return backups[i].blog < backups[j].blog
+		}
+		return backups[i].path < backups[j].path
+	})
+	return backups, nil
+}
+
+// Restore re-creates the entries backed up in backupDir and writes each to a
+// new file in dir, named by filenameTemplate. Entries that still exist on the
+// blog and files that already exist are left alone.
+func (s *Syncer) Restore(backupDir, dir, filenameTemplate string, uuids []string, dryRun bool) (*SyncResult, error) {
+	return s.RestoreContext(context.Background(), backupDir, dir, filenameTemplate, uuids, dryRun)
+}
+
+// RestoreContext is Restore with cancellation.
+func (s *Syncer) RestoreContext(ctx context.Context, backupDir, dir, filenameTemplate string, uuids []string, dryRun bool) (*SyncResult, error) {
+	tmpl, err := template.New("filename").Parse(filenameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	backups, err := findBackups(backupDir, uuids)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	if !dryRun {
+		defer s.saveState(result)
+	}
+
+	var client hatena.AtomPubClient
+	remoteUUIDs := make(map[string]bool)
+	blog := ""
+	for _, backup := range backups {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if backup.blog != blog {
+			blog = backup.blog
+			if client, err = s.restoreClient(blog); err != nil {
+				return result, err
+			}
+			remoteEntries, err := hatena.GetAllEntries(ctx, client)
+			if err != nil {
+				return result, fmt.Errorf("failed to get remote entries of %s: %w", blog, err)
+			}
+			remoteUUIDs = make(map[string]bool)
+			for _, entry := range remoteEntries {
+				remoteUUIDs[hatena.ExtractUUIDFromEntryID(entry.ID)] = true
+			}
+		}
+
+		if err := s.restoreEntry(ctx, client, backup, remoteUUIDs, tmpl, dir, dryRun, result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+func (s *Syncer) restoreClient(blog string) (hatena.AtomPubClient, error) {
+	if blog == s.blog {
+		return s.client, nil
+	}
+	if s.clientForBlog == nil {
+		return nil, fmt.Errorf("backup is of blog %s but only %s is configured", blog, s.blog)
+	}
+	client, err := s.clientForBlog(blog)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for blog %s: %w", blog, err)
+	}
+	return client, nil
+}
+
+// restoreEntry re-creates one backed up entry. Only errors that should stop
+// the restore, such as the daily posting limit, are returned.
+func (s *Syncer) restoreEntry(ctx context.Context, client hatena.AtomPubClient, backup backupFile, remoteUUIDs map[string]bool, tmpl *template.Template, dir string, dryRun bool, result *SyncResult) error {
+	data, err := os.ReadFile(backup.path)
+	if err != nil {
+		result.addError(fmt.Errorf("failed to read backup: %w", err))
+		return nil
+	}
+	entry, err := hatena.DecodeEntry(data)
+	if err != nil {
+		result.addError(fmt.Errorf("failed to parse backup %s: %w", backup.path, err))
+		return nil
+	}
+
+	if remoteUUIDs[backup.uuid] {
+		log.Printf("= %s (still exists)", entry.URL)
+		result.count("skip")
+		return nil
+	}
+
+	restored, err := articleFromEntry(entry)
+	if err != nil {
+		result.addError(err)
+		return nil
+	}
+	filePath, err := pullFilePath(tmpl, dir, restored)
+	if err != nil {
+		result.addError(err)
+		return nil
+	}
+	if _, err := os.Stat(filePath); err == nil {
+		result.addError(fmt.Errorf("refusing to overwrite %s with restored entry %s", filePath, entry.URL))
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		result.addError(err)
+		return nil
+	}
+
+	if dryRun {
+		log.Printf("+ %s (would restore %s)", filePath, entry.URL)
+		result.count("create")
+		return nil
+	}
+
+	restored.UUID = ""
+	created, err := client.CreateEntryContext(ctx, restored)
+	if err != nil {
+		if isDailyLimitExceeded(err) {
+			return fmt.Errorf("daily posting limit exceeded: %w", err)
+		}
+		result.addError(fmt.Errorf("failed to restore %s: %w", entry.URL, err))
+		return nil
+	}
+
+	restored.UUID = hatena.ExtractUUIDFromEntryID(created.ID)
+	restored.FilePath = filePath
+	if backup.blog != s.blog {
+		restored.Blog = backup.blog
+		err = saveWithFrontmatter(restored, struct {
+			Blog string `yaml:"blog"`
+		}{backup.blog})
+	} else {
+		err = article.Save(restored)
+	}
+	if err != nil {
+		result.addError(fmt.Errorf("restored %s as %s but failed to save %s: %w", entry.URL, created.URL, filePath, err))
+		return nil
+	}
+	s.recordSync(restored, created)
+	log.Printf("+ %s", filePath)
+	result.count("create")
+	return nil
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+func TestSyncBackupsOrphans(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"deleted with delete-orphan": {
+			change: func(f *fixture) {
+				if err := os.Remove(filepath.Join(f.dir, "a.md")); err != nil {
+					f.t.Fatal(err)
+				}
+			},
+			opts: Options{DeleteOrphan: true},
+			want: counts{Deleted: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 0 {
+					t.Errorf("entry was not deleted")
+				}
+				backups, err := filepath.Glob(filepath.Join(f.dir, state.DirName, BackupDirName, "*", testBlog, "*"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(backups) != 2 {
+					t.Errorf("want a Markdown and an XML backup, got %v", backups)
+				}
+			},
+		},
+		"backup failed": {
+			change: func(f *fixture) {
+				if err := os.Remove(filepath.Join(f.dir, "a.md")); err != nil {
+					f.t.Fatal(err)
+				}
+				// A file where the backup directory goes
+				f.write(filepath.Join(state.DirName, BackupDirName), "")
+			},
+			opts:   Options{DeleteOrphan: true},
+			errors: 1,
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 1 {
+					t.Errorf("entry was deleted without a backup")
+				}
+			},
+		},
+	})
+}
+
+func TestDeleteDuplicatesAndRestore(t *testing.T) {
+	f := newFixture(t)
+	uuids := f.postCopies("same\n", "same\n")
+	s := f.syncer(Options{})
+
+	groups, err := s.FindDuplicatesContext(context.Background(), f.articles(), KeepLinked, 0.9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &SyncResult{}
+	for _, group := range groups {
+		if err := s.DeleteDuplicatesContext(context.Background(), group, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(result.Errors) > 0 || result.Deleted != 1 {
+		t.Fatalf("deleted %d, errors %v", result.Deleted, result.Errors)
+	}
+	if entries := f.blog.Entries(); len(entries) != 1 || hatena.ExtractUUIDFromEntryID(entries[0].ID) != uuids[1] {
+		t.Fatalf("the newest copy should be left, got %v", entries)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(f.dir, state.DirName, BackupDirName, "*"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("want one backup directory, got %v (%v)", backups, err)
+	}
+
+	tests := []struct {
+		name    string
+		dryRun  bool
+		want    counts
+		errors  int
+		entries int
+	}{
+		{"dry run", true, counts{Created: 1}, 0, 1},
+		{"restore", false, counts{Created: 1}, 0, 2},
+		// The file of the first restore is in the way
+		{"again", false, counts{}, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.syncer(Options{}).Restore(backups[0], f.dir, "{{.UUID}}.md", nil, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := countsOf(result); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(result.Errors) != tt.errors {
+				t.Errorf("got errors %v, want %d", result.Errors, tt.errors)
+			}
+			if len(f.blog.Entries()) != tt.entries {
+				t.Errorf("blog has %d entries, want %d", len(f.blog.Entries()), tt.entries)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(f.dir, uuids[0]+".md")); err != nil {
+		t.Errorf("restored entry was not written: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// blogSet is the part of a run that targets one blog. Each set is fetched,
+// planned and pruned of orphans on its own.
+type blogSet struct {
+	blog          string
+	client        hatena.AtomPubClient
+	articles      []*article.Article
+	remoteEntries []*article.HatenaEntry
+	actions       []DryRunAction
+}
+
+// blogOf returns the blog an article is synced to.
+func (s *Syncer) blogOf(art *article.Article) string {
+	if art.Blog != "" {
+		return art.Blog
+	}
+	return s.blog
+}
+
+// groupByBlog splits the articles by target blog, the configured blog first
+// and the others by name. The configured blog is only synced when articles
+// target it, or when nothing else is synced so that an empty set still
+// behaves as before. An article whose entry was synced to another blog is
+// an error, as its entry cannot be moved between blogs.
+func (s *Syncer) groupByBlog(localArticles []*article.Article) ([]*blogSet, error) {
+	sets := make(map[string]*blogSet)
+	var others []string
+	syncedTo := s.syncedBlogs()
+
+	for _, art := range localArticles {
+		blog := s.blogOf(art)
+		if synced, ok := syncedTo[art.UUID]; ok && art.UUID != "" && synced != blog {
+			return nil, fmt.Errorf("%s targets blog %s but its entry %s is on blog %s; remove its uuid to post it to %s as a new entry", art.FilePath, blog, art.UUID, synced, blog)
+		}
+
+		set, ok := sets[blog]
+		if !ok {
+			set = &blogSet{blog: blog}
+			sets[blog] = set
+			if blog != s.blog {
+				others = append(others, blog)
+			}
+		}
+		set.articles = append(set.articles, art)
+	}
+	sort.Strings(others)
+
+	var result []*blogSet
+	if set, ok := sets[s.blog]; ok || len(others) == 0 {
+		if !ok {
+			set = &blogSet{blog: s.blog}
+		}
+		set.client = s.client
+		result = append(result, set)
+	}
+
+	for _, blog := range others {
+		if s.clientForBlog == nil {
+			return nil, fmt.Errorf("articles target blog %s but only %s is configured", blog, s.blog)
+		}
+		client, err := s.clientForBlog(blog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client for blog %s: %w", blog, err)
+		}
+		sets[blog].client = client
+		result = append(result, sets[blog])
+	}
+
+	return result, nil
+}
+
+// syncedBlogs maps the UUIDs in the sync state to the blog of their entry.
+func (s *Syncer) syncedBlogs() map[string]string {
+	blogs := make(map[string]string)
+	if s.state == nil {
+		return blogs
+	}
+
+	for _, filePath := range s.state.Files() {
+		entry, _ := s.state.Get(filePath)
+		blogs[entry.UUID] = s.stateBlog(entry)
+	}
+	return blogs
+}
+
+// planBlogs fetches the entries of every blog and plans its actions.
+func (s *Syncer) planBlogs(ctx context.Context, sets []*blogSet, dryRun bool) error {
+	for _, set := range sets {
+		if len(sets) > 1 {
+			log.Printf("Blog %s: %d articles", set.blog, len(set.articles))
+		}
+
+		remoteEntries, err := hatena.GetAllEntries(ctx, set.client)
+		if err != nil {
+			if len(sets) > 1 {
+				return fmt.Errorf("failed to get remote entries of %s: %w", set.blog, err)
+			}
+			return fmt.Errorf("failed to get remote entries: %w", err)
+		}
+		set.remoteEntries = remoteEntries
+
+		// Check for duplicate entries first
+		duplicates := s.FindDuplicateEntries(remoteEntries)
+		s.ReportDuplicateEntries(duplicates)
+
+		s.reconcileState(set.blog, set.articles, remoteEntries, dryRun)
+		set.actions = s.planActions(set.articles, remoteEntries)
+		for i := range set.actions {
+			set.actions[i].identify(set.blog)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenamem"
+)
+
+const otherBlog = "other.hatenablog.com"
+
+// blogsOf returns a ClientForBlog for in-memory blogs made as asked for.
+func blogsOf(blogs map[string]*hatenamem.Blog) func(string) (hatena.AtomPubClient, error) {
+	return func(blog string) (hatena.AtomPubClient, error) {
+		if blogs[blog] == nil {
+			blogs[blog] = hatenamem.New("me", blog)
+		}
+		return blogs[blog], nil
+	}
+}
+
+func TestSyncRoutesArticlesToBlogs(t *testing.T) {
+	f := newFixture(t)
+	f.write("a.md", "---\ntitle: A\n---\nbody\n")
+	f.write("b.md", "---\ntitle: B\nblog: "+otherBlog+"\n---\nbody\n")
+	f.write("en/c.md", "---\ntitle: C\n---\nbody\n")
+	f.write("en/"+article.DirConfigFileName, "blog: "+otherBlog+"\n")
+
+	arts := f.articles()
+	if err := article.ResolveBlogs(arts, f.dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.syncer(Options{}).SyncArticles(arts); err == nil {
+		t.Error("synced to a blog without ClientForBlog")
+	}
+
+	blogs := make(map[string]*hatenamem.Blog)
+	result, err := f.syncer(Options{ClientForBlog: blogsOf(blogs)}).SyncArticles(arts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 3 || len(result.Errors) > 0 {
+		t.Fatalf("created %d, errors %v", result.Created, result.Errors)
+	}
+
+	var titles []string
+	for _, entry := range blogs[otherBlog].Entries() {
+		titles = append(titles, entry.Title)
+	}
+	if len(f.blog.Entries()) != 1 || len(titles) != 2 {
+		t.Errorf("%s has %d entries, %s has %v", testBlog, len(f.blog.Entries()), otherBlog, titles)
+	}
+}
+
+func TestSyncMovedBetweenBlogs(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"moved to another blog": {
+			change:  func(f *fixture) { f.replace("a.md", "title: A", "title: A\nblog: "+otherBlog) },
+			opts:    Options{ClientForBlog: blogsOf(make(map[string]*hatenamem.Blog))},
+			wantErr: true,
+		},
+		"moved without its uuid": {
+			change: func(f *fixture) {
+				f.write("a.md", uuidLine.ReplaceAllString(f.read("a.md"), ""))
+				f.replace("a.md", "title: A", "title: A\nblog: "+otherBlog)
+			},
+			opts: Options{ClientForBlog: blogsOf(make(map[string]*hatenamem.Blog))},
+			want: counts{Created: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 1 {
+					t.Errorf("the entry on %s was touched", testBlog)
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,225 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/diff"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// Policies for choosing which copy of a duplicated entry to keep. Copies a
+// local file links to are always kept.
+const (
+	// KeepLinked keeps only linked copies, or the newest if none is linked.
+	KeepLinked = "linked"
+	// KeepNewest keeps the most recently edited copy as well.
+	KeepNewest = "newest"
+)
+
+// DuplicateGroup is a set of remote entries of one blog sharing a title.
+type DuplicateGroup struct {
+	Blog   string
+	Title  string
+	Copies []DuplicateCopy
+
+	client hatena.AtomPubClient
+}
+
+// DuplicateCopy is one entry of a DuplicateGroup.
+type DuplicateCopy struct {
+	Entry *article.HatenaEntry
+	// LinkedFile is the local file linked to the entry, by its uuid or the
+	// sync state, if any.
+	LinkedFile string
+	// Similarity compares the content with the first kept copy, from 0 to 1.
+	Similarity float64
+	Keep       bool
+	// Reason tells why the copy is kept or deleted.
+	Reason string
+}
+
+// Deletions returns the copies that are not kept.
+func (g *DuplicateGroup) Deletions() []DuplicateCopy {
+	var deletions []DuplicateCopy
+	for _, c := range g.Copies {
+		if !c.Keep {
+			deletions = append(deletions, c)
+		}
+	}
+	return deletions
+}
+
+// FindDuplicatesContext fetches the entries of every blog the articles
+// target and groups duplicated titles, deciding which copies to keep. Copies
+// less similar than minSimilarity, from 0 to 1, to the kept one are kept as
+// well, since they are likely different posts that share a title.
+func (s *Syncer) FindDuplicatesContext(ctx context.Context, localArticles []*article.Article, keep string, minSimilarity float64) ([]*DuplicateGroup, error) {
+	if keep != KeepLinked && keep != KeepNewest {
+		return nil, fmt.Errorf("invalid keep policy %q", keep)
+	}
+	if minSimilarity < 0 || minSimilarity > 1 {
+		return nil, fmt.Errorf("invalid minimum similarity %v", minSimilarity)
+	}
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	sets, err := s.groupByBlog(localArticles)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*DuplicateGroup
+	for _, set := range sets {
+		remoteEntries, err := hatena.GetAllEntries(ctx, set.client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get remote entries of %s: %w", set.blog, err)
+		}
+
+		linked := s.linkedFiles(set)
+		duplicates := s.FindDuplicateEntries(remoteEntries)
+		sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Title < duplicates[j].Title })
+
+		for _, dup := range duplicates {
+			group := &DuplicateGroup{Blog: set.blog, Title: dup.Title, client: set.client}
+			for _, entry := range dup.Entries {
+				group.Copies = append(group.Copies, DuplicateCopy{
+					Entry:      entry,
+					LinkedFile: linked[hatena.ExtractUUIDFromEntryID(entry.ID)],
+				})
+			}
+			group.choose(keep, minSimilarity)
+			groups = append(groups, group)
+		}
+	}
+
+	return groups, nil
+}
+
+// linkedFiles maps the uuids of the blog's entries to the local files linked
+// to them. Links recorded in the sync state count too, so that a file that
+// lost its uuid does not leave its entry unprotected.
+func (s *Syncer) linkedFiles(set *blogSet) map[string]string {
+	linked := make(map[string]string)
+	if s.state != nil {
+		for _, filePath := range s.state.Files() {
+			entry, _ := s.state.Get(filePath)
+			if s.stateBlog(entry) == set.blog {
+				linked[entry.UUID] = filePath
+			}
+		}
+	}
+	for _, art := range set.articles {
+		if art.UUID != "" {
+			linked[art.UUID] = art.FilePath
+		}
+	}
+	return linked
+}
+
+// choose marks the copies to keep and scores the others against the first
+// kept one. Copies below minSimilarity are kept too.
+func (g *DuplicateGroup) choose(keep string, minSimilarity float64) {
+	newest := 0
+	for i, c := range g.Copies {
+		if editedTime(c.Entry).After(editedTime(g.Copies[newest].Entry)) {
+			newest = i
+		}
+	}
+
+	reference := -1
+	for i := range g.Copies {
+		if g.Copies[i].LinkedFile != "" {
+			g.Copies[i].Keep = true
+			g.Copies[i].Reason = "linked to a local file"
+			if reference < 0 {
+				reference = i
+			}
+		}
+	}
+	if reference < 0 {
+		g.Copies[newest].Keep = true
+		g.Copies[newest].Reason = "newest copy, and no copy is linked"
+		reference = newest
+	} else if keep == KeepNewest && !g.Copies[newest].Keep {
+		g.Copies[newest].Keep = true
+		g.Copies[newest].Reason = "newest copy"
+	}
+
+	for i := range g.Copies {
+		c := &g.Copies[i]
+		c.Similarity = diff.Similarity(g.Copies[reference].Entry.Content, c.Entry.Content)
+		switch {
+		case c.Keep:
+		case c.Similarity < minSimilarity:
+			c.Keep = true
+			c.Reason = fmt.Sprintf("only %.0f%% similar to the kept copy, likely a different post (minimum %.0f%%)", c.Similarity*100, minSimilarity*100)
+		default:
+			c.Reason = fmt.Sprintf("unlinked copy, %.0f%% similar to the kept copy", c.Similarity*100)
+		}
+	}
+}
+
+func editedTime(entry *article.HatenaEntry) time.Time {
+	t, _ := time.Parse(time.RFC3339, entry.LastModified())
+	return t
+}
+
+// ReportDuplicateGroup prints a group with what would happen to each copy.
+func (s *Syncer) ReportDuplicateGroup(n int, group *DuplicateGroup) {
+	fmt.Printf("%d. Title: \"%s\" on %s (%d entries)\n", n, group.Title, group.Blog, len(group.Copies))
+	for _, c := range group.Copies {
+		decision := "delete"
+		if c.Keep {
+			decision = "keep"
+		}
+		linked := "not linked"
+		if c.LinkedFile != "" {
+			linked = "linked to " + c.LinkedFile
+		}
+
+		fmt.Printf("   %-6s UUID=%s, Updated=%s, %s, similarity %.0f%%\n",
+			decision, hatena.ExtractUUIDFromEntryID(c.Entry.ID), c.Entry.LastModified(), linked, c.Similarity*100)
+		if c.Entry.URL != "" {
+			fmt.Printf("          URL=%s\n", c.Entry.URL)
+		}
+		fmt.Printf("          Reason: %s\n", c.Reason)
+	}
+	fmt.Println()
+}
+
+// DeleteDuplicatesContext deletes the copies of group that are not kept.
+// Copies linked to a local file are never deleted.
+func (s *Syncer) DeleteDuplicatesContext(ctx context.Context, group *DuplicateGroup, result *SyncResult) error {
+	for _, c := range group.Deletions() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.LinkedFile != "" {
+			continue
+		}
+
+		entryID := hatena.ExtractEntryIDFromEditURL(c.Entry.EditURL)
+		if entryID == "" {
+			result.addError(fmt.Errorf("failed to extract entry ID from edit URL: %s", c.Entry.EditURL))
+			continue
+		}
+		if err := s.backupEntry(group.Blog, c.Entry); err != nil {
+			result.addError(fmt.Errorf("not deleting duplicate %s: %w", c.Entry.URL, err))
+			continue
+		}
+		if err := group.client.DeleteEntryContext(ctx, entryID); err != nil {
+			result.addError(fmt.Errorf("failed to delete duplicate %s: %w", c.Entry.URL, err))
+			continue
+		}
+		log.Printf("- %s", c.Entry.URL)
+		result.count("delete")
+	}
+
+	return nil
+}
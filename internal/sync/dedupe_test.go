@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+// postCopies creates an entry titled "Weekly" per content, oldest first,
+// and returns their UUIDs.
+func (f *fixture) postCopies(contents ...string) []string {
+	f.t.Helper()
+
+	var uuids []string
+	for _, content := range contents {
+		f.tick()
+		entry, err := f.blog.CreateEntryContext(context.Background(), &article.Article{Title: "Weekly", Content: content})
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		uuids = append(uuids, hatena.ExtractUUIDFromEntryID(entry.ID))
+	}
+	return uuids
+}
+
+func TestFindDuplicates(t *testing.T) {
+	tests := map[string]struct {
+		contents []string
+		// linked is the copy a local file is linked to, or -1
+		linked        int
+		keep          string
+		minSimilarity float64
+		wantKept      []int
+	}{
+		"newest of unlinked copies": {
+			contents:      []string{"same\n", "same\n", "same\n"},
+			linked:        -1,
+			keep:          KeepLinked,
+			minSimilarity: 0.9,
+			wantKept:      []int{2},
+		},
+		"linked copy": {
+			contents:      []string{"same\n", "same\n"},
+			linked:        0,
+			keep:          KeepLinked,
+			minSimilarity: 0.9,
+			wantKept:      []int{0},
+		},
+		"linked and newest copies": {
+			contents:      []string{"same\n", "same\n", "same\n"},
+			linked:        0,
+			keep:          KeepNewest,
+			minSimilarity: 0.9,
+			wantKept:      []int{0, 2},
+		},
+		"similar copies below the minimum similarity": {
+			contents:      []string{"a\nb\nc\nd\n", "a\nb\nc\nx\n"},
+			linked:        -1,
+			keep:          KeepLinked,
+			minSimilarity: 0.9,
+			wantKept:      []int{0, 1},
+		},
+		"similar copies above the minimum similarity": {
+			contents:      []string{"a\nb\nc\nd\n", "a\nb\nc\nx\n"},
+			linked:        -1,
+			keep:          KeepLinked,
+			minSimilarity: 0.7,
+			wantKept:      []int{1},
+		},
+		"different posts sharing a title": {
+			contents:      []string{"first week\n", "second week\n"},
+			linked:        -1,
+			keep:          KeepLinked,
+			minSimilarity: 0.9,
+			wantKept:      []int{0, 1},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t)
+			uuids := f.postCopies(tt.contents...)
+			if tt.linked >= 0 {
+				f.write("weekly.md", fmt.Sprintf("---\ntitle: Weekly\nuuid: %q\n---\n%s", uuids[tt.linked], tt.contents[tt.linked]))
+			}
+
+			groups, err := f.syncer(Options{}).FindDuplicatesContext(context.Background(), f.articles(), tt.keep, tt.minSimilarity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(groups) != 1 {
+				t.Fatalf("got %d groups, want 1", len(groups))
+			}
+
+			kept := make(map[string]bool)
+			for _, c := range groups[0].Copies {
+				if c.Reason == "" {
+					t.Errorf("no reason for %s", c.Entry.ID)
+				}
+				if c.Keep {
+					kept[hatena.ExtractUUIDFromEntryID(c.Entry.ID)] = true
+				}
+			}
+			want := make(map[string]bool)
+			for _, i := range tt.wantKept {
+				want[uuids[i]] = true
+			}
+			if fmt.Sprint(kept) != fmt.Sprint(want) {
+				t.Errorf("kept %v, want %v", kept, want)
+			}
+		})
+	}
+}
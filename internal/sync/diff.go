@@ -0,0 +1,78 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/diff"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// writeDiff writes what an update, pull or conflict would change on the
+// receiving side: the blog for updates and conflicts, the file for pulls.
+func (s *Syncer) writeDiff(w io.Writer, action *DryRunAction, color bool) (diff.Stats, error) {
+	local, remote := action.Article, action.RemoteEntry
+	localText, remoteText := localEntryText(local, remote), remoteEntryText(remote)
+	localName, remoteName := "local "+local.FilePath, "remote "+remote.URL
+
+	opts := diff.Options{Context: diffContext, Color: color}
+	if action.Type == "pull" {
+		return diff.Unified(w, localName, remoteName, localText, remoteText, opts)
+	}
+	return diff.Unified(w, remoteName, localName, remoteText, localText, opts)
+}
+
+// diffsAction reports whether -diff shows a diff for the action.
+func diffsAction(action *DryRunAction) bool {
+	switch action.Type {
+	case "update", "pull", "adopt", "conflict":
+		return action.Article != nil && action.RemoteEntry != nil
+	}
+	return false
+}
+
+// remoteEntryText renders the fields of an entry that articles set, in the
+// shape of an article file, so that they diff line by line.
+func remoteEntryText(remote *article.HatenaEntry) string {
+	return entryText(remote.Title, remote.Path, remote.Updated, remote.IsDraft, remote.Categories, remote.Content)
+}
+
+// localEntryText renders what the article would send. Dates are only shown
+// as changed when they differ in time, not just in time zone, and a missing
+// path or date shows the remote one it leaves alone.
+func localEntryText(local *article.Article, remote *article.HatenaEntry) string {
+	path := remote.Path
+	if !samePath(local, remote) {
+		path = local.Path
+	}
+	date := remote.Updated
+	if !sameDate(local, remote) {
+		date = local.DateTime.Format(time.RFC3339)
+	}
+	return entryText(local.Title, path, date, local.Draft, local.Categories, local.Content)
+}
+
+func entryText(title, path, date string, draft bool, categories []string, content string) string {
+	// Category order carries no meaning on Hatena Blog
+	sorted := append([]string(nil), categories...)
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "title: %s\n", title)
+	fmt.Fprintf(&b, "path: %s\n", path)
+	fmt.Fprintf(&b, "date: %s\n", date)
+	fmt.Fprintf(&b, "draft: %s\n", yesNo(draft))
+	b.WriteString("categories:\n")
+	for _, category := range sorted {
+		fmt.Fprintf(&b, "  - %s\n", category)
+	}
+	b.WriteString("---\n")
+	b.WriteString(content)
+	return b.String()
+}
@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+const planVersion = 2
+
+// Plan is a reviewed list of actions. ApplyPlan carries it out only if
+// planning again gives exactly the same actions.
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Dir is the absolute path of the article directory the plan was made
+	// for. The file paths of the actions are relative to it.
+	Dir          string         `json:"dir"`
+	Blog         string         `json:"blog"`
+	DeleteOrphan bool           `json:"delete_orphan"`
+	Adopt        string         `json:"adopt,omitempty"`
+	Actions      []DryRunAction `json:"actions"`
+}
+
+// identify fills in the fields that tie the action to the local and remote
+// state it was planned against.
+func (a *DryRunAction) identify(blog string) {
+	a.Blog = blog
+	if a.Article != nil {
+		a.FilePath = a.Article.FilePath
+		a.UUID = a.Article.UUID
+		a.ContentHash = a.Article.Hash()
+	}
+	if a.RemoteEntry != nil {
+		a.UUID = hatena.ExtractUUIDFromEntryID(a.RemoteEntry.ID)
+		a.EntryID = hatena.ExtractEntryIDFromEditURL(a.RemoteEntry.EditURL)
+		a.RemoteUpdated = a.RemoteEntry.LastModified()
+		a.RemoteHash = a.RemoteEntry.Hash()
+	}
+}
+
+// sameTarget reports whether two actions do the same thing to the same
+// versions of an article and entry.
+func (a *DryRunAction) sameTarget(b *DryRunAction) bool {
+	return a.Type == b.Type && a.Blog == b.Blog && a.FilePath == b.FilePath && a.UUID == b.UUID &&
+		a.EntryID == b.EntryID && a.ContentHash == b.ContentHash && a.RemoteUpdated == b.RemoteUpdated && a.RemoteHash == b.RemoteHash
+}
+
+func (s *Syncer) Plan(localArticles []*article.Article) (*Plan, error) {
+	return s.PlanContext(context.Background(), localArticles)
+}
+
+// PlanContext plans a sync without changing anything and prints the same
+// report as a dry run.
+func (s *Syncer) PlanContext(ctx context.Context, localArticles []*article.Article) (*Plan, error) {
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	sets, err := s.groupByBlog(localArticles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planBlogs(ctx, sets, true); err != nil {
+		return nil, err
+	}
+
+	dir, err := filepath.Abs(s.stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve article directory: %w", err)
+	}
+
+	var actions []DryRunAction
+	for _, set := range sets {
+		actions = append(actions, set.actions...)
+	}
+	s.reportPlan(actions)
+
+	return &Plan{
+		Version:      planVersion,
+		CreatedAt:    time.Now(),
+		Dir:          dir,
+		Blog:         s.blog,
+		DeleteOrphan: s.deleteOrphan,
+		Adopt:        s.adopt,
+		Actions:      relativePaths(actions, dir),
+	}, nil
+}
+
+// Conflicts returns the conflict actions, which keep a plan from being
+// applied.
+func (p *Plan) Conflicts() []DryRunAction {
+	var conflicts []DryRunAction
+	for _, action := range p.Actions {
+		if action.Type == "conflict" {
+			conflicts = append(conflicts, action)
+		}
+	}
+	return conflicts
+}
+
+func (s *Syncer) ApplyPlan(plan *Plan, localArticles []*article.Article) (*SyncResult, error) {
+	return s.ApplyPlanContext(context.Background(), plan, localArticles)
+}
+
+// ApplyPlanContext plans again and, if the local files and remote entries
+// still give exactly the actions of plan, applies them. Otherwise nothing is
+// changed and an error describes the drift.
+func (s *Syncer) ApplyPlanContext(ctx context.Context, plan *Plan, localArticles []*article.Article) (*SyncResult, error) {
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", plan.Version)
+	}
+	if plan.Blog != s.blog {
+		return nil, fmt.Errorf("plan was made for blog %s, not %s", plan.Blog, s.blog)
+	}
+	if plan.DeleteOrphan != s.deleteOrphan {
+		return nil, fmt.Errorf("plan was made with delete-orphan %t", plan.DeleteOrphan)
+	}
+	if plan.Adopt != s.adopt {
+		return nil, fmt.Errorf("plan was made with adopt policy %q", plan.Adopt)
+	}
+	if conflicts := plan.Conflicts(); len(conflicts) > 0 {
+		return nil, fmt.Errorf("plan has %d conflicts and cannot be applied", len(conflicts))
+	}
+
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	// Plan on copies of the articles, so that UUIDs restored from the sync
+	// state are only written to the files once the plan is known to hold
+	planned := make([]*article.Article, len(localArticles))
+	for i, art := range localArticles {
+		copied := *art
+		planned[i] = &copied
+	}
+
+	sets, err := s.groupByBlog(planned)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planBlogs(ctx, sets, true); err != nil {
+		return nil, err
+	}
+
+	dir, err := filepath.Abs(s.stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve article directory: %w", err)
+	}
+
+	var actions []DryRunAction
+	for _, set := range sets {
+		actions = append(actions, set.actions...)
+	}
+	if drift := planDrift(plan.Actions, relativePaths(actions, dir)); len(drift) > 0 {
+		for _, line := range drift {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		return &SyncResult{}, fmt.Errorf("local files or remote entries changed since the plan was made; plan again")
+	}
+
+	result := &SyncResult{}
+	defer s.saveState(result)
+
+	for i, art := range localArticles {
+		if art.UUID == "" && planned[i].UUID != "" {
+			if err := article.UpdateArticleUUID(art, planned[i].UUID); err != nil {
+				log.Printf("Warning: failed to update UUID in file %s: %v", art.FilePath, err)
+			}
+		}
+	}
+
+	for _, set := range sets {
+		if err := s.applyActions(ctx, set, result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// relativePaths returns a copy of actions with file paths relative to dir,
+// so that a plan does not depend on the directory it was made or applied
+// from.
+func relativePaths(actions []DryRunAction, dir string) []DryRunAction {
+	relative := make([]DryRunAction, len(actions))
+	for i, action := range actions {
+		relative[i] = action
+		if action.FilePath == "" {
+			continue
+		}
+		abs, err := filepath.Abs(action.FilePath)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, abs); err == nil {
+			relative[i].FilePath = filepath.ToSlash(rel)
+		}
+	}
+	return relative
+}
+
+// planDrift describes how the actions planned now differ from the planned
+// ones, in plan order.
+func planDrift(planned, current []DryRunAction) []string {
+	var drift []string
+	for i := 0; i < len(planned) || i < len(current); i++ {
+		switch {
+		case i >= len(current):
+			drift = append(drift, fmt.Sprintf("- planned %s, now missing", describeAction(&planned[i])))
+		case i >= len(planned):
+			drift = append(drift, fmt.Sprintf("+ now %s, not planned", describeAction(&current[i])))
+		case planned[i].sameTarget(&current[i]):
+		case planned[i].Type == current[i].Type && planned[i].FilePath == current[i].FilePath && planned[i].EntryID == current[i].EntryID:
+			drift = append(drift, fmt.Sprintf("! %s: %s", describeAction(&planned[i]), describeChange(&planned[i], &current[i])))
+		default:
+			drift = append(drift, fmt.Sprintf("! planned %s, now %s", describeAction(&planned[i]), describeAction(&current[i])))
+		}
+	}
+	return drift
+}
+
+// describeChange tells which side of an action changed since planning.
+func describeChange(planned, current *DryRunAction) string {
+	var changes []string
+	if planned.ContentHash != current.ContentHash || planned.UUID != current.UUID {
+		changes = append(changes, "local file changed")
+	}
+	if planned.RemoteUpdated != current.RemoteUpdated || planned.RemoteHash != current.RemoteHash {
+		changes = append(changes, "remote entry changed")
+	}
+	if planned.Blog != current.Blog {
+		changes = append(changes, fmt.Sprintf("blog changed from %s to %s", planned.Blog, current.Blog))
+	}
+	return strings.Join(changes, ", ")
+}
+
+func describeAction(a *DryRunAction) string {
+	target := a.FilePath
+	if target == "" {
+		target = "entry " + a.EntryID
+	}
+	return fmt.Sprintf("%s %s", a.Type, target)
+}
+
+// WritePlan saves plan as JSON.
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	// Write a temporary file and rename it, so that an existing plan is
+	// never left half written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
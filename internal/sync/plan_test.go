@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+func TestApplyPlanDrift(t *testing.T) {
+	tests := map[string]struct {
+		// change runs between planning and applying the plan
+		change  func(f *fixture)
+		wantErr bool
+	}{
+		"unchanged": {},
+		"file edited": {
+			change:  func(f *fixture) { f.replace("a.md", "planned edit", "later edit") },
+			wantErr: true,
+		},
+		"file added": {
+			change:  func(f *fixture) { f.write("b.md", "---\ntitle: B\n---\nanother\n") },
+			wantErr: true,
+		},
+		"entry edited": {
+			change: func(f *fixture) {
+				f.tick()
+				f.editRemote(func(e *article.HatenaEntry) { e.Title = "Edited" })
+			},
+			wantErr: true,
+		},
+		"file deleted": {
+			change: func(f *fixture) {
+				if err := os.Remove(filepath.Join(f.dir, "a.md")); err != nil {
+					f.t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+		"entry deleted": {
+			change: func(f *fixture) {
+				entryID := hatena.ExtractEntryIDFromEditURL(f.blog.Entries()[0].EditURL)
+				if err := f.blog.DeleteEntryContext(context.Background(), entryID); err != nil {
+					f.t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+		"entry edited within the second of planning": {
+			change: func(f *fixture) {
+				f.editRemote(func(e *article.HatenaEntry) { e.Title = "Edited" })
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t)
+			f.write("a.md", "---\ntitle: A\n---\nbody\n")
+			f.mustSync(Options{})
+			f.replace("a.md", "body", "planned edit")
+
+			plan, err := f.syncer(Options{}).Plan(f.articles())
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "plan.json")
+			if err := WritePlan(path, plan); err != nil {
+				t.Fatal(err)
+			}
+			if plan, err = ReadPlan(path); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.change != nil {
+				tt.change(f)
+			}
+			before := f.blog.Entries()
+
+			result, err := f.syncer(Options{}).ApplyPlan(plan, f.articles())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if after := f.blog.Entries(); !reflect.DeepEqual(after, before) {
+					t.Errorf("blog was changed despite drift: %+v", after)
+				}
+				return
+			}
+			after := f.blog.Entries()[0]
+			if got := countsOf(result); got != (counts{Updated: 1}) {
+				t.Errorf("got %+v", got)
+			}
+			if after.Content != "planned edit" {
+				t.Errorf("remote content is %q", after.Content)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+)
+
+const DefaultFilenameTemplate = "{{.Slug}}.md"
+
+// FilenameData is passed to the filename template of PullArticles.
+type FilenameData struct {
+	Title string
+	Path  string
+	UUID  string
+	Date  time.Time
+	// Slug is Path, or UUID for entries without a custom URL.
+	Slug string
+}
+
+// PullArticles writes every remote entry into dir. Entries already linked to
+// a local file by UUID update that file, and only when overwrite is set;
+// other entries get a new file named by filenameTemplate. Existing files are
+// never replaced by an unrelated entry.
+func (s *Syncer) PullArticles(localArticles []*article.Article, dir, filenameTemplate string, overwrite bool) (*SyncResult, error) {
+	return s.PullArticlesContext(context.Background(), localArticles, dir, filenameTemplate, overwrite)
+}
+
+// PullArticlesContext is PullArticles with cancellation. Files written before
+// ctx is done are kept and reported in the result.
+func (s *Syncer) PullArticlesContext(ctx context.Context, localArticles []*article.Article, dir, filenameTemplate string, overwrite bool) (*SyncResult, error) {
+	tmpl, err := template.New("filename").Parse(filenameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
+
+	remoteEntries, err := hatena.GetAllEntries(ctx, s.client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote entries: %w", err)
+	}
+
+	localUUIDMap := make(map[string]*article.Article)
+	for _, art := range localArticles {
+		if art.UUID != "" {
+			localUUIDMap[art.UUID] = art
+		}
+	}
+
+	result := &SyncResult{}
+	defer s.saveState(result)
+
+	for _, remoteEntry := range remoteEntries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if isRedirectStub(remoteEntry) {
+			continue
+		}
+
+		pulled, err := articleFromEntry(remoteEntry)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		if localArticle, exists := localUUIDMap[pulled.UUID]; exists {
+			if !s.needsUpdate(localArticle, remoteEntry) {
+				s.recordSync(localArticle, remoteEntry)
+				log.Printf("= %s", localArticle.FilePath)
+				result.Skipped++
+				continue
+			}
+			if !overwrite {
+				log.Printf("Warning: %s differs from the remote entry, not overwriting (use -overwrite)", localArticle.FilePath)
+				result.Skipped++
+				continue
+			}
+
+			keepUnsetFields(pulled, localArticle)
+			pulled.FilePath = localArticle.FilePath
+			if err := article.Save(pulled); err != nil {
+				result.Errors = append(result.Errors, err)
+				continue
+			}
+			s.recordSync(pulled, remoteEntry)
+			log.Printf("~ %s", pulled.FilePath)
+			result.Updated++
+			continue
+		}
+
+		filePath, err := pullFilePath(tmpl, dir, pulled)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		if _, err := os.Stat(filePath); err == nil {
+			err := fmt.Errorf("refusing to overwrite %s with unrelated entry %s", filePath, remoteEntry.URL)
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+
+		pulled.FilePath = filePath
+		if err := article.Save(pulled); err != nil {
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		s.recordSync(pulled, remoteEntry)
+		log.Printf("+ %s", filePath)
+		result.Created++
+	}
+
+	return result, nil
+}
+
+// keepUnsetFields keeps leaving the path and date of a pulled article to
+// Hatena if the local file did.
+func keepUnsetFields(pulled, local *article.Article) {
+	if local.Path == "" {
+		pulled.Path = ""
+	}
+	if local.Date == "" {
+		pulled.Date = ""
+		pulled.DateTime = time.Time{}
+	}
+}
+
+func articleFromEntry(entry *article.HatenaEntry) (*article.Article, error) {
+	uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
+	if uuid == "" {
+		return nil, fmt.Errorf("failed to extract UUID from entry ID: %s", entry.ID)
+	}
+
+	art := &article.Article{
+		Title:      entry.Title,
+		Path:       entry.Path,
+		UUID:       uuid,
+		Categories: entry.Categories,
+		Draft:      entry.IsDraft,
+		Date:       entry.Updated,
+		Content:    entry.Content,
+	}
+
+	if entry.Updated != "" {
+		t, err := time.Parse(time.RFC3339, entry.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("invalid updated time %q for entry %s: %w", entry.Updated, entry.ID, err)
+		}
+		art.DateTime = t
+	}
+
+	return art, nil
+}
+
+func pullFilePath(tmpl *template.Template, dir string, art *article.Article) (string, error) {
+	data := FilenameData{
+		Title: art.Title,
+		Path:  art.Path,
+		UUID:  art.UUID,
+		Date:  art.DateTime,
+		Slug:  art.Path,
+	}
+	if data.Slug == "" {
+		data.Slug = art.UUID
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render filename for %s: %w", art.Title, err)
+	}
+
+	name := filepath.Clean(filepath.FromSlash(buf.String()))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("filename %q for %s is outside the article directory", buf.String(), art.Title)
+	}
+
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+)
+
+func TestPullArticlesNewEntries(t *testing.T) {
+	f := newFixture(t)
+	for _, art := range []*article.Article{
+		{Title: "With path", Path: "custom", Content: "first"},
+		{Title: "Without path", Content: "second"},
+	} {
+		if _, err := f.blog.CreateEntryContext(context.Background(), art); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.write("custom.md", "---\ntitle: Unrelated\n---\nlocal\n")
+
+	result, err := f.syncer(Options{}).PullArticles(f.articles(), f.dir, DefaultFilenameTemplate, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// custom.md is in the way of the entry with a custom URL
+	if result.Created != 1 || len(result.Errors) != 1 {
+		t.Fatalf("created %d, errors %v", result.Created, result.Errors)
+	}
+	if !strings.Contains(f.read("custom.md"), "local") {
+		t.Errorf("custom.md was overwritten:\n%s", f.read("custom.md"))
+	}
+
+	// Hatena gives entries without a custom URL a date-based one
+	for _, entry := range f.blog.Entries() {
+		if entry.Path == "custom" {
+			continue
+		}
+		name := entry.Path + ".md"
+		if content := f.read(name); !strings.Contains(content, "second") || !strings.Contains(content, "uuid:") {
+			t.Errorf("%s was not pulled:\n%s", name, content)
+		}
+	}
+}
+
+func TestPullArticlesOverwrite(t *testing.T) {
+	tests := map[string]struct {
+		file      string
+		overwrite bool
+		want      []string
+		wantNot   []string
+	}{
+		"keeps local edits": {
+			file: "---\ntitle: A\n---\nbody\n",
+			want: []string{"edited locally"},
+		},
+		"keeps an unset date and path": {
+			file:      "---\ntitle: A\n---\nbody\n",
+			overwrite: true,
+			want:      []string{"edited remotely"},
+			wantNot:   []string{"date:", "path:"},
+		},
+		"overwrites a set date and path": {
+			file:      "---\ntitle: A\npath: a\ndate: 2024-01-01T00:00:00Z\n---\nbody\n",
+			overwrite: true,
+			want:      []string{"edited remotely", "path: a", "date: \"2024-01-01T00:00:00Z\""},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t)
+			f.write("a.md", tt.file)
+			f.mustSync(Options{})
+			f.replace("a.md", "body", "edited locally")
+			f.tick()
+			f.editRemote(func(e *article.HatenaEntry) { e.Content = "edited remotely" })
+
+			result, err := f.syncer(Options{}).PullArticles(f.articles(), f.dir, "{{.UUID}}.md", tt.overwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Errors) > 0 {
+				t.Fatal(result.Errors)
+			}
+
+			content := f.read("a.md")
+			for _, s := range tt.want {
+				if !strings.Contains(content, s) {
+					t.Errorf("a.md does not contain %q:\n%s", s, content)
+				}
+			}
+			for _, s := range tt.wantNot {
+				if strings.Contains(content, s) {
+					t.Errorf("a.md contains %q:\n%s", s, content)
+				}
+			}
+		})
+	}
+}
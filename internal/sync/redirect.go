@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+)
+
+// redirectMarker identifies redirect stubs, which have no local file and
+// are kept out of orphan deletion, duplicate reports and pulls.
+const redirectMarker = "<!-- hatenablog-atompub-client: redirect -->"
+
+func isRedirectStub(entry *article.HatenaEntry) bool {
+	return strings.Contains(entry.Content, redirectMarker)
+}
+
+// redirectStub returns an entry for the old URL of a moved entry that links
+// to its new URL. It keeps the old date so that it stays where the entry
+// was in the archive.
+func redirectStub(old, moved *article.HatenaEntry) *article.Article {
+	stub := &article.Article{
+		Title:   old.Title,
+		Path:    old.Path,
+		Content: fmt.Sprintf("%s\nこの記事は移動しました：[%s](%s)\n", redirectMarker, moved.Title, moved.URL),
+	}
+	if t, err := time.Parse(time.RFC3339, old.Updated); err == nil {
+		stub.DateTime = t
+	}
+	return stub
+}
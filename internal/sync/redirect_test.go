@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyncPathChanges(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"path changed": {
+			change: func(f *fixture) { f.replace("a.md", "title: A", "title: A\npath: moved") },
+			want:   counts{Updated: 1},
+			check: func(t *testing.T, f *fixture) {
+				if entries := f.blog.Entries(); len(entries) != 1 || entries[0].Path != "moved" {
+					t.Errorf("got entries %+v", entries)
+				}
+			},
+		},
+		"path changed with redirect stubs": {
+			change: func(f *fixture) { f.replace("a.md", "title: A", "title: A\npath: moved") },
+			opts:   Options{RedirectStubs: true},
+			want:   counts{Updated: 1},
+			check: func(t *testing.T, f *fixture) {
+				entries := f.blog.Entries()
+				if len(entries) != 2 {
+					t.Fatalf("blog has %d entries, want 2", len(entries))
+				}
+				moved, stub := entries[0], entries[1]
+				if moved.Path != "moved" {
+					moved, stub = stub, moved
+				}
+				if !isRedirectStub(stub) || !strings.Contains(stub.Content, moved.URL) || stub.Path == "moved" {
+					t.Errorf("got stub %+v for %s", stub, moved.URL)
+				}
+
+				// The stub is neither an orphan nor a duplicate
+				if got := countsOf(f.mustSync(Options{DeleteOrphan: true})); got != (counts{Skipped: 1}) {
+					t.Errorf("next sync: got %+v", got)
+				}
+			},
+		},
+	})
+}
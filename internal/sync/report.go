@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"encoding/json"
+	"io"
+	gosync "sync"
+)
+
+// ActionReport is the outcome of one planned or applied action.
+type ActionReport struct {
+	Type     string `json:"type"`
+	Blog     string `json:"blog,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// RedirectURL is the old URL of an updated entry whose path changed,
+	// if a redirect stub was created there.
+	RedirectURL string `json:"redirect_url,omitempty"`
+	// Diff is the unified diff of a planned update, pull or conflict, with
+	// the Diff option.
+	Diff string `json:"diff,omitempty"`
+}
+
+// ResultReport is the serializable form of a SyncResult.
+type ResultReport struct {
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Pulled    int            `json:"pulled"`
+	Adopted   int            `json:"adopted"`
+	Skipped   int            `json:"skipped"`
+	Deleted   int            `json:"deleted"`
+	Conflicts []ActionReport `json:"conflicts"`
+	Errors    []string       `json:"errors"`
+}
+
+// Reporter receives every action in plan order, in place of the text
+// output. Calls are never concurrent.
+type Reporter interface {
+	Action(report ActionReport)
+}
+
+func newActionReport(action *DryRunAction) ActionReport {
+	report := ActionReport{
+		Type:     action.Type,
+		Blog:     action.Blog,
+		FilePath: action.FilePath,
+		UUID:     action.UUID,
+		Reason:   action.Reason,
+	}
+	if action.RemoteEntry != nil {
+		report.URL = action.RemoteEntry.URL
+	}
+	return report
+}
+
+// fail records a non-fatal error of the action.
+func (r *ActionReport) fail(result *SyncResult, err error) {
+	result.addError(err)
+	r.Error = err.Error()
+}
+
+// Report returns the counts, conflicts and errors of the result.
+func (r *SyncResult) Report() ResultReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := ResultReport{
+		Created:   r.Created,
+		Updated:   r.Updated,
+		Pulled:    r.Pulled,
+		Adopted:   r.Adopted,
+		Skipped:   r.Skipped,
+		Deleted:   r.Deleted,
+		Conflicts: []ActionReport{},
+		Errors:    []string{},
+	}
+	for i := range r.Conflicts {
+		report.Conflicts = append(report.Conflicts, newActionReport(&r.Conflicts[i]))
+	}
+	for _, err := range r.Errors {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	return report
+}
+
+// JSONReporter writes actions and the final result as JSON. Streaming
+// writes one object per line (NDJSON) as actions happen; otherwise a single
+// document is written by Finish.
+type JSONReporter struct {
+	mu      gosync.Mutex
+	encoder *json.Encoder
+	stream  bool
+	actions []ActionReport
+	// err is the first failed write; nothing is written after it.
+	err error
+}
+
+func NewJSONReporter(w io.Writer, stream bool) *JSONReporter {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if !stream {
+		encoder.SetIndent("", "  ")
+	}
+	return &JSONReporter{encoder: encoder, stream: stream, actions: []ActionReport{}}
+}
+
+func (r *JSONReporter) Action(report ActionReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stream {
+		if r.err == nil {
+			r.err = r.encoder.Encode(struct {
+				Event string `json:"event"`
+				ActionReport
+			}{"action", report})
+		}
+		return
+	}
+	r.actions = append(r.actions, report)
+}
+
+// Finish writes the result, which may be nil if the run failed before
+// doing anything, and the error the run ended with, if any. It returns the
+// first error writing any output, including streamed actions.
+func (r *JSONReporter) Finish(result *SyncResult, runErr error, interrupted bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	var resultReport *ResultReport
+	if result != nil {
+		report := result.Report()
+		resultReport = &report
+	}
+	var errMessage string
+	if runErr != nil {
+		errMessage = runErr.Error()
+	}
+
+	if r.stream {
+		return r.encoder.Encode(struct {
+			Event       string        `json:"event"`
+			Result      *ResultReport `json:"result"`
+			Error       string        `json:"error,omitempty"`
+			Interrupted bool          `json:"interrupted,omitempty"`
+		}{"result", resultReport, errMessage, interrupted})
+	}
+
+	return r.encoder.Encode(struct {
+		Actions     []ActionReport `json:"actions"`
+		Result      *ResultReport  `json:"result"`
+		Error       string         `json:"error,omitempty"`
+		Interrupted bool           `json:"interrupted,omitempty"`
+	}{r.actions, resultReport, errMessage, interrupted})
+}
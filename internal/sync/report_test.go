@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingWriter fails the writes from the failFrom-th through the
+// failTo-th, counting from 1.
+type failingWriter struct {
+	bytes.Buffer
+	failFrom, failTo, writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.failFrom <= w.writes && w.writes <= w.failTo {
+		return 0, errors.New("closed pipe")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestJSONReporterStream(t *testing.T) {
+	tests := map[string]struct {
+		failFrom, failTo int
+		// want is the number of lines written
+		want    int
+		wantErr bool
+	}{
+		"written":              {want: 3},
+		"closed before result": {failFrom: 3, failTo: 3, want: 2, wantErr: true},
+		"closed mid-stream":    {failFrom: 2, failTo: 3, want: 1, wantErr: true},
+		// The result must not pass for the whole output
+		"action lost": {failFrom: 1, failTo: 1, want: 0, wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := &failingWriter{failFrom: tt.failFrom, failTo: tt.failTo}
+			r := NewJSONReporter(w, true)
+			r.Action(ActionReport{Type: "create", FilePath: "a.md"})
+			r.Action(ActionReport{Type: "skip", FilePath: "b.md"})
+
+			err := r.Finish(&SyncResult{Created: 1, Skipped: 1}, nil, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if lines := strings.Count(w.String(), "\n"); lines != tt.want {
+				t.Errorf("wrote %d lines, want %d:\n%s", lines, tt.want, w.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+func (s *Syncer) loadState() error {
+	s.state = nil
+	if s.stateDir == "" {
+		return nil
+	}
+
+	st, err := state.Load(s.stateDir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync state: %w", err)
+	}
+	s.state = st
+	return nil
+}
+
+func (s *Syncer) saveState(result *SyncResult) {
+	if s.state == nil {
+		return
+	}
+
+	if err := s.state.Save(); err != nil {
+		result.addError(fmt.Errorf("failed to save sync state: %w", err))
+	}
+}
+
+// reconcileState matches the sync state against the files on disk before
+// planning. It restores UUIDs that are recorded for a file but missing from
+// it, follows renamed files and reports tracked files that were deleted.
+// Only files recorded for blog are considered. Files are only rewritten
+// outside of dry runs.
+func (s *Syncer) reconcileState(blog string, localArticles []*article.Article, remoteEntries []*article.HatenaEntry, dryRun bool) {
+	if s.state == nil {
+		return
+	}
+
+	remoteUUIDs := make(map[string]bool)
+	for _, entry := range remoteEntries {
+		remoteUUIDs[hatena.ExtractUUIDFromEntryID(entry.ID)] = true
+	}
+
+	localPaths := make(map[string]bool)
+	localUUIDs := make(map[string]bool)
+	for _, art := range localArticles {
+		localPaths[filepath.Clean(art.FilePath)] = true
+		if art.UUID != "" {
+			localUUIDs[art.UUID] = true
+		}
+	}
+
+	// Files whose UUID went missing, e.g. after a bad merge
+	for _, art := range localArticles {
+		if art.UUID != "" {
+			continue
+		}
+		if entry, ok := s.state.Get(art.FilePath); ok && s.stateBlog(entry) == blog && !localUUIDs[entry.UUID] {
+			s.restoreUUID(art, entry.UUID, dryRun)
+			localUUIDs[entry.UUID] = true
+		}
+	}
+
+	// Files that are recorded but no longer on disk
+	for _, filePath := range s.state.Files() {
+		if localPaths[filePath] {
+			continue
+		}
+		entry, _ := s.state.Get(filePath)
+		if s.stateBlog(entry) != blog {
+			continue
+		}
+
+		if moved := s.findMovedArticle(localArticles, entry); moved != nil {
+			if moved.UUID == "" {
+				s.restoreUUID(moved, entry.UUID, dryRun)
+			}
+			log.Printf("Renamed: %s → %s", filePath, moved.FilePath)
+			s.state.Rename(filePath, moved.FilePath)
+			continue
+		}
+
+		// Deleted on both sides, or still referenced by another file
+		if !remoteUUIDs[entry.UUID] || localUUIDs[entry.UUID] {
+			s.state.Delete(filePath)
+			continue
+		}
+
+		if s.deleteOrphan {
+			log.Printf("Deleted locally: %s", filePath)
+		} else {
+			log.Printf("Warning: %s was deleted locally; run with -delete-orphan to delete %s", filePath, entry.URL)
+		}
+	}
+}
+
+// forgetEntry drops every file linked to the entry uuid of blog.
+func (s *Syncer) forgetEntry(blog, uuid string) {
+	if s.state == nil {
+		return
+	}
+
+	for _, filePath := range s.state.Files() {
+		entry, _ := s.state.Get(filePath)
+		if entry.UUID == uuid && s.stateBlog(entry) == blog {
+			s.state.Delete(filePath)
+		}
+	}
+}
+
+// stateBlog returns the blog of a recorded entry. Entries recorded without
+// one belong to the configured blog.
+func (s *Syncer) stateBlog(entry state.Entry) string {
+	if entry.Blog != "" {
+		return entry.Blog
+	}
+	return s.blog
+}
+
+// findMovedArticle looks for the new location of a recorded file among the
+// untracked articles, first by UUID and then by identical content.
+func (s *Syncer) findMovedArticle(localArticles []*article.Article, entry state.Entry) *article.Article {
+	var untracked []*article.Article
+	for _, art := range localArticles {
+		if _, tracked := s.state.Get(art.FilePath); !tracked {
+			untracked = append(untracked, art)
+		}
+	}
+
+	for _, art := range untracked {
+		if art.UUID == entry.UUID {
+			return art
+		}
+	}
+	for _, art := range untracked {
+		if art.UUID == "" && art.Hash() == entry.ContentHash {
+			return art
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) restoreUUID(art *article.Article, uuid string, dryRun bool) {
+	log.Printf("Restoring UUID %s of %s from sync state", uuid, art.FilePath)
+	if dryRun {
+		art.UUID = uuid
+		return
+	}
+
+	if err := article.UpdateArticleUUID(art, uuid); err != nil {
+		log.Printf("Warning: failed to update UUID in file %s: %v", art.FilePath, err)
+	}
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+var uuidLine = regexp.MustCompile(`(?m)^uuid: .*\n`)
+
+func TestSyncState(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"renamed": {
+			change: func(f *fixture) {
+				if err := os.Rename(filepath.Join(f.dir, "a.md"), filepath.Join(f.dir, "b.md")); err != nil {
+					f.t.Fatal(err)
+				}
+			},
+			want: counts{Skipped: 1},
+			check: func(t *testing.T, f *fixture) {
+				st, err := state.Load(f.dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := st.Get(filepath.Join(f.dir, "a.md")); ok {
+					t.Error("state still has a.md")
+				}
+				if _, ok := st.Get(filepath.Join(f.dir, "b.md")); !ok {
+					t.Error("state has no b.md")
+				}
+			},
+		},
+		"lost its uuid": {
+			change: func(f *fixture) {
+				f.write("a.md", uuidLine.ReplaceAllString(f.read("a.md"), ""))
+			},
+			want: counts{Skipped: 1},
+			check: func(t *testing.T, f *fixture) {
+				if !strings.Contains(f.read("a.md"), "uuid:") {
+					t.Errorf("uuid was not restored to a.md:\n%s", f.read("a.md"))
+				}
+			},
+		},
+		"renamed and lost its uuid": {
+			change: func(f *fixture) {
+				if err := os.Remove(filepath.Join(f.dir, "a.md")); err != nil {
+					f.t.Fatal(err)
+				}
+				f.write("b.md", "---\ntitle: A\n---\nbody\n")
+			},
+			want: counts{Skipped: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 1 {
+					t.Errorf("blog has %d entries, want 1", len(f.blog.Entries()))
+				}
+				if !strings.Contains(f.read("b.md"), "uuid:") {
+					t.Errorf("uuid was not restored to b.md:\n%s", f.read("b.md"))
+				}
+			},
+		},
+		"deleted": {
+			change: func(f *fixture) {
+				if err := os.Remove(filepath.Join(f.dir, "a.md")); err != nil {
+					f.t.Fatal(err)
+				}
+			},
+			want: counts{},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 1 {
+					t.Errorf("entry was deleted without -delete-orphan")
+				}
+			},
+		},
+	})
+}
@@ -1,32 +1,114 @@
 package sync
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strings"
+	gosync "sync"
+	"time"
 
 	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/diff"
 	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
 )
 
 type Syncer struct {
-	client       *hatena.Client
-	deleteOrphan bool
+	client        hatena.AtomPubClient
+	blog          string
+	clientForBlog func(blog string) (hatena.AtomPubClient, error)
+	deleteOrphan  bool
+	stateDir      string
+	concurrency   int
+	reporter      Reporter
+	diff          bool
+	color         bool
+	redirectStubs bool
+	adopt         string
+	confirmAdopt  func(local *article.Article, remote *article.HatenaEntry) (bool, error)
+
+	// state is loaded at the start of each run when stateDir is set
+	state *state.State
+
+	// backupDir is where entries are backed up before deletion, created
+	// by the first deletion
+	backupMu  gosync.Mutex
+	backupDir string
 }
 
+type Options struct {
+	DeleteOrphan bool
+	// StateDir is the article directory whose sync state is read before
+	// and written after each run. Without it every article is compared in
+	// full and the local file always wins.
+	// Entries are backed up under it before they are deleted.
+	StateDir string
+	// Concurrency is the number of create, update and delete requests
+	// in flight at once. Values below 1 mean 1.
+	Concurrency int
+	// Blog is the blog the client talks to. Articles with no blog of their
+	// own, or this one, are synced through the client.
+	Blog string
+	// ClientForBlog returns the client for any other blog an article
+	// targets. Each blog is synced separately, and orphans are only looked
+	// for among the entries of blogs that articles target.
+	ClientForBlog func(blog string) (hatena.AtomPubClient, error)
+	// Reporter, if set, receives every action in place of the text lines
+	// and conflict report.
+	Reporter Reporter
+	// Diff shows what each planned update, pull and conflict would change
+	// as a unified diff, colored with Color.
+	Diff  bool
+	Color bool
+	// RedirectStubs creates an entry at the old URL of every entry whose
+	// path changes, linking to the new URL.
+	RedirectStubs bool
+	// Adopt is the policy for articles without a UUID that match a remote
+	// entry no article is linked to: AdoptAsk, AdoptYes or AdoptNo. Empty
+	// means AdoptNo.
+	Adopt string
+	// ConfirmAdopt asks whether to link an article to a matching entry
+	// under AdoptAsk. If it is nil or fails to get an answer, the article is
+	// skipped.
+	ConfirmAdopt func(local *article.Article, remote *article.HatenaEntry) (bool, error)
+}
+
+// SyncResult is safe to update from concurrently applied actions.
 type SyncResult struct {
-	Created int
-	Updated int
-	Skipped int
-	Deleted int
-	Errors  []error
+	Created   int
+	Updated   int
+	Pulled    int
+	Adopted   int
+	Skipped   int
+	Deleted   int
+	Conflicts []DryRunAction
+	Errors    []error
+
+	mu gosync.Mutex
 }
 
+// DryRunAction is a planned action. The exported fields other than Article
+// and RemoteEntry identify what the action was planned against, so that a
+// serialized plan can be checked before it is applied.
 type DryRunAction struct {
-	Type        string // "create", "update", "skip", "delete"
-	Article     *article.Article
-	RemoteEntry *article.HatenaEntry
-	Reason      string
+	Type     string `json:"type"` // "create", "update", "pull", "adopt", "skip", "conflict", "delete"
+	Blog     string `json:"blog,omitempty"`
+	FilePath string `json:"file_path,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	EntryID  string `json:"entry_id,omitempty"`
+	// ContentHash is the hash of the local article when planned.
+	ContentHash string `json:"content_hash,omitempty"`
+	// RemoteUpdated and RemoteHash are the last modification and hash of
+	// the remote entry when planned.
+	RemoteUpdated string `json:"remote_updated,omitempty"`
+	RemoteHash    string `json:"remote_hash,omitempty"`
+	Reason        string `json:"reason"`
+
+	Article     *article.Article     `json:"-"`
+	RemoteEntry *article.HatenaEntry `json:"-"`
 }
 
 type DuplicateEntry struct {
@@ -34,134 +116,118 @@ type DuplicateEntry struct {
 	Entries []*article.HatenaEntry
 }
 
-func NewSyncer(client *hatena.Client) *Syncer {
+func NewSyncer(client hatena.AtomPubClient) *Syncer {
 	return &Syncer{client: client, deleteOrphan: false}
 }
 
-func NewSyncerWithDelete(client *hatena.Client, deleteOrphan bool) *Syncer {
+func NewSyncerWithDelete(client hatena.AtomPubClient, deleteOrphan bool) *Syncer {
 	return &Syncer{client: client, deleteOrphan: deleteOrphan}
 }
 
+func NewSyncerWithOptions(client hatena.AtomPubClient, opts Options) *Syncer {
+	return &Syncer{
+		client:        client,
+		blog:          opts.Blog,
+		clientForBlog: opts.ClientForBlog,
+		deleteOrphan:  opts.DeleteOrphan,
+		stateDir:      opts.StateDir,
+		concurrency:   opts.Concurrency,
+		reporter:      opts.Reporter,
+		diff:          opts.Diff,
+		color:         opts.Color,
+		redirectStubs: opts.RedirectStubs,
+		adopt:         opts.Adopt,
+		confirmAdopt:  opts.ConfirmAdopt,
+	}
+}
+
 func (s *Syncer) SyncArticles(localArticles []*article.Article) (*SyncResult, error) {
+	return s.SyncArticlesContext(context.Background(), localArticles)
+}
+
+// SyncArticlesContext is SyncArticles with cancellation. When ctx is done no
+// further actions are started, and the result of the actions that were
+// carried out is returned along with the context's error.
+func (s *Syncer) SyncArticlesContext(ctx context.Context, localArticles []*article.Article) (*SyncResult, error) {
 	result := &SyncResult{}
 
-	remoteEntries, err := s.client.GetEntries()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get remote entries: %w", err)
+	if err := s.loadState(); err != nil {
+		return nil, err
 	}
+	defer s.saveState(result)
 
-	// Check for duplicate entries first
-	duplicates := s.FindDuplicateEntries(remoteEntries)
-	s.ReportDuplicateEntries(duplicates)
-
-	remoteUUIDMap := make(map[string]*article.HatenaEntry)
-	for _, entry := range remoteEntries {
-		uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
-		if uuid != "" {
-			remoteUUIDMap[uuid] = entry
-		}
+	sets, err := s.groupByBlog(localArticles)
+	if err != nil {
+		return nil, err
 	}
+	if err := s.planBlogs(ctx, sets, false); err != nil {
+		return nil, err
+	}
+	s.confirmAdoptions(sets)
 
-	localUUIDMap := make(map[string]*article.Article)
-	for _, art := range localArticles {
-		if art.UUID != "" {
-			localUUIDMap[art.UUID] = art
+	// Refuse to touch anything while an article has diverged on both sides
+	for _, set := range sets {
+		for _, action := range set.actions {
+			if action.Type == "conflict" {
+				result.Conflicts = append(result.Conflicts, action)
+			}
 		}
 	}
+	if len(result.Conflicts) > 0 {
+		s.reportConflicts(result.Conflicts)
+		return result, fmt.Errorf("%d articles were changed both locally and on Hatena Blog", len(result.Conflicts))
+	}
 
-	// Delete orphaned articles first
-	if s.deleteOrphan {
-		for uuid, remoteEntry := range remoteUUIDMap {
-			if _, exists := localUUIDMap[uuid]; !exists {
-				entryID := hatena.ExtractEntryIDFromEditURL(remoteEntry.EditURL)
-				if entryID == "" {
-					err := fmt.Errorf("failed to extract entry ID from edit URL: %s", remoteEntry.EditURL)
-					result.Errors = append(result.Errors, err)
-					continue
-				}
-
-				err := s.client.DeleteEntry(entryID)
-				if err != nil {
-					err = fmt.Errorf("failed to delete article %s: %w", remoteEntry.Title, err)
-					result.Errors = append(result.Errors, err)
-					continue
-				}
-				log.Printf("- %s", remoteEntry.URL)
-				result.Deleted++
-			}
+	for _, set := range sets {
+		if err := s.applyActions(ctx, set, result); err != nil {
+			return result, err
 		}
 	}
 
-	// Then create/update local articles
-	for _, localArticle := range localArticles {
-		if localArticle.UUID == "" {
-			createdEntry, err := s.client.CreateEntry(localArticle)
-			if err != nil {
-				if isDailyLimitExceeded(err) {
-					return result, fmt.Errorf("daily posting limit exceeded: %w", err)
-				}
-				err = fmt.Errorf("failed to create article %s: %w", localArticle.Title, err)
-				result.Errors = append(result.Errors, err)
-				continue
-			}
+	return result, nil
+}
 
-			uuid := hatena.ExtractUUIDFromEntryID(createdEntry.ID)
-			if uuid != "" {
-				if err := article.UpdateArticleUUID(localArticle, uuid); err != nil {
-					log.Printf("Warning: failed to update UUID in file %s: %v", localArticle.FilePath, err)
-				}
-			}
+func (s *Syncer) DryRunSyncArticles(localArticles []*article.Article) (*SyncResult, error) {
+	return s.DryRunSyncArticlesContext(context.Background(), localArticles)
+}
 
-			log.Printf("+ %s", localArticle.FilePath)
-			result.Created++
-			continue
-		}
+func (s *Syncer) DryRunSyncArticlesContext(ctx context.Context, localArticles []*article.Article) (*SyncResult, error) {
+	result := &SyncResult{}
 
-		if remoteEntry, exists := remoteUUIDMap[localArticle.UUID]; exists {
-			if s.needsUpdate(localArticle, remoteEntry) {
-				entryID := hatena.ExtractEntryIDFromEditURL(remoteEntry.EditURL)
-				if entryID == "" {
-					err := fmt.Errorf("failed to extract entry ID from edit URL: %s", remoteEntry.EditURL)
-					result.Errors = append(result.Errors, err)
-					continue
-				}
+	if err := s.loadState(); err != nil {
+		return nil, err
+	}
 
-				_, err := s.client.UpdateEntry(entryID, localArticle)
-				if err != nil {
-					err = fmt.Errorf("failed to update article %s: %w", localArticle.Title, err)
-					result.Errors = append(result.Errors, err)
-					continue
-				}
-				log.Printf("~ %s", localArticle.FilePath)
-				result.Updated++
-			} else {
-				log.Printf("= %s", localArticle.FilePath)
-				result.Skipped++
-			}
+	sets, err := s.groupByBlog(localArticles)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planBlogs(ctx, sets, true); err != nil {
+		return nil, err
+	}
+
+	var actions []DryRunAction
+	for _, set := range sets {
+		actions = append(actions, set.actions...)
+	}
+	for _, action := range actions {
+		if action.Type == "conflict" {
+			result.Conflicts = append(result.Conflicts, action)
 		} else {
-			// UUID exists but not found in remote - should not happen in normal flow
-			// This case should be handled by the UUID-less creation logic above
-			log.Printf("Warning: Article %s has UUID but not found in remote", localArticle.FilePath)
-			result.Skipped++
+			result.count(action.Type)
 		}
 	}
 
+	s.reportPlan(actions)
 	return result, nil
 }
 
-func (s *Syncer) DryRunSyncArticles(localArticles []*article.Article) (*SyncResult, error) {
-	result := &SyncResult{}
+// planActions decides what to do with every local article and, with
+// deleteOrphan, every remote entry without a local counterpart. Deletions
+// come first, followed by local articles in their original order.
+func (s *Syncer) planActions(localArticles []*article.Article, remoteEntries []*article.HatenaEntry) []DryRunAction {
 	var actions []DryRunAction
 
-	remoteEntries, err := s.client.GetEntries()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get remote entries: %w", err)
-	}
-
-	// Check for duplicate entries first
-	duplicates := s.FindDuplicateEntries(remoteEntries)
-	s.ReportDuplicateEntries(duplicates)
-
 	remoteUUIDMap := make(map[string]*article.HatenaEntry)
 	for _, entry := range remoteEntries {
 		uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
@@ -177,88 +243,465 @@ func (s *Syncer) DryRunSyncArticles(localArticles []*article.Article) (*SyncResu
 		}
 	}
 
+	// Entries that articles without a UUID turn out to be are not orphans
+	adoptions := make(map[*article.Article]adoption)
+	if s.adopt != "" && s.adopt != AdoptNo {
+		adoptions = findAdoptions(localArticles, remoteEntries)
+	}
+	adoptedUUIDs := make(map[string]bool)
+	for _, a := range adoptions {
+		adoptedUUIDs[hatena.ExtractUUIDFromEntryID(a.entry.ID)] = true
+	}
+
 	// Check for orphaned articles first
 	if s.deleteOrphan {
-		for uuid, remoteEntry := range remoteUUIDMap {
+		for _, remoteEntry := range remoteEntries {
+			uuid := hatena.ExtractUUIDFromEntryID(remoteEntry.ID)
+			if uuid == "" || isRedirectStub(remoteEntry) || adoptedUUIDs[uuid] {
+				continue
+			}
 			if _, exists := localUUIDMap[uuid]; !exists {
 				actions = append(actions, DryRunAction{
 					Type:        "delete",
 					RemoteEntry: remoteEntry,
 					Reason:      "Article no longer exists locally",
 				})
-				result.Deleted++
 			}
 		}
 	}
 
 	// Then check local articles for create/update
 	for _, localArticle := range localArticles {
+		if a, ok := adoptions[localArticle]; ok {
+			actions = append(actions, s.planAdoption(localArticle, a))
+			continue
+		}
+
 		if localArticle.UUID == "" {
 			actions = append(actions, DryRunAction{
 				Type:    "create",
 				Article: localArticle,
 				Reason:  "New article (no UUID assigned yet)",
 			})
-			result.Created++
 			continue
 		}
-		if remoteEntry, exists := remoteUUIDMap[localArticle.UUID]; exists {
-			if s.needsUpdate(localArticle, remoteEntry) {
-				var changes []string
-				if localArticle.Title != remoteEntry.Title {
-					changes = append(changes, fmt.Sprintf("title: '%s' → '%s'", remoteEntry.Title, localArticle.Title))
-				}
-				if localArticle.Content != remoteEntry.Content {
-					changes = append(changes, "content: modified")
-				}
 
-				actions = append(actions, DryRunAction{
-					Type:        "update",
-					Article:     localArticle,
-					RemoteEntry: remoteEntry,
-					Reason:      fmt.Sprintf("Changes: %v", changes),
-				})
-				result.Updated++
-			} else {
-				actions = append(actions, DryRunAction{
-					Type:        "skip",
-					Article:     localArticle,
-					RemoteEntry: remoteEntry,
-					Reason:      "No changes detected",
-				})
-				result.Skipped++
-			}
-		} else {
+		remoteEntry, exists := remoteUUIDMap[localArticle.UUID]
+		if !exists {
+			// UUID exists but not found in remote - should not happen in normal flow
 			actions = append(actions, DryRunAction{
-				Type:    "create",
+				Type:    "skip",
 				Article: localArticle,
-				Reason:  "New article (UUID not found in remote)",
+				Reason:  "UUID not found in remote",
 			})
-			result.Created++
+			continue
 		}
+
+		actions = append(actions, s.planArticle(localArticle, remoteEntry))
+	}
+
+	return actions
+}
+
+// planAdoption plans linking an article to the entry it matched. As for any
+// article without a recorded sync, the local file then wins.
+func (s *Syncer) planAdoption(local *article.Article, a adoption) DryRunAction {
+	reason := fmt.Sprintf("Matches remote entry %s by %s", a.entry.URL, a.by)
+	if s.needsUpdate(local, a.entry) {
+		reason += fmt.Sprintf("; Changes: %v", describeChanges(local, a.entry))
+	}
+
+	return DryRunAction{
+		Type:        "adopt",
+		Article:     local,
+		RemoteEntry: a.entry,
+		Reason:      reason,
+	}
+}
+
+// planArticle compares a linked article against the state recorded at the
+// last sync to tell which side changed.
+func (s *Syncer) planArticle(local *article.Article, remote *article.HatenaEntry) DryRunAction {
+	action := DryRunAction{Article: local, RemoteEntry: remote}
+	baseline, hasBaseline := s.baseline(local)
+
+	if hasBaseline && baseline.ContentHash == local.Hash() && !remoteChangedSince(baseline, remote) {
+		action.Type = "skip"
+		action.Reason = "Unchanged since last sync"
+		return action
+	}
+
+	if !s.needsUpdate(local, remote) {
+		action.Type = "skip"
+		action.Reason = "No changes detected"
+		return action
+	}
+
+	changes := fmt.Sprintf("Changes: %v", describeChanges(local, remote))
+
+	// Without a recorded sync the local file wins, as it always has
+	if !hasBaseline {
+		action.Type = "update"
+		action.Reason = changes
+		return action
+	}
+
+	localChanged := local.Hash() != baseline.ContentHash
+	remoteChanged := remoteChangedSince(baseline, remote)
+
+	switch {
+	case localChanged && remoteChanged:
+		action.Type = "conflict"
+		action.Reason = fmt.Sprintf("Changed locally and remotely (remote edited %s, last synced %s)", remote.LastModified(), baseline.RemoteUpdated)
+	case remoteChanged:
+		action.Type = "pull"
+		action.Reason = fmt.Sprintf("Changed remotely (edited %s)", remote.LastModified())
+	default:
+		action.Type = "update"
+		action.Reason = changes
+	}
+
+	return action
+}
+
+// applyAction carries out a single planned action and fills in its report.
+// Only errors that must stop the whole run are returned; others are
+// collected in result.
+func (s *Syncer) applyAction(ctx context.Context, set *blogSet, action DryRunAction, result *SyncResult, logger *log.Logger, report *ActionReport) error {
+	switch action.Type {
+	case "delete":
+		remoteEntry := action.RemoteEntry
+		entryID := hatena.ExtractEntryIDFromEditURL(remoteEntry.EditURL)
+		if entryID == "" {
+			err := fmt.Errorf("failed to extract entry ID from edit URL: %s", remoteEntry.EditURL)
+			report.fail(result, err)
+			return nil
+		}
+
+		if err := s.backupEntry(set.blog, remoteEntry); err != nil {
+			err = fmt.Errorf("not deleting article %s: %w", remoteEntry.Title, err)
+			report.fail(result, err)
+			return nil
+		}
+		err := set.client.DeleteEntryContext(ctx, entryID)
+		if err != nil {
+			err = fmt.Errorf("failed to delete article %s: %w", remoteEntry.Title, err)
+			report.fail(result, err)
+			return nil
+		}
+		s.forgetEntry(set.blog, hatena.ExtractUUIDFromEntryID(remoteEntry.ID))
+		s.logAction(logger, "- %s", remoteEntry.URL)
+		result.count("delete")
+
+	case "create":
+		localArticle := action.Article
+		createdEntry, err := set.client.CreateEntryContext(ctx, localArticle)
+		if err != nil {
+			if isDailyLimitExceeded(err) {
+				err = fmt.Errorf("daily posting limit exceeded: %w", err)
+				report.Error = err.Error()
+				return err
+			}
+			err = fmt.Errorf("failed to create article %s: %w", localArticle.Title, err)
+			report.fail(result, err)
+			return nil
+		}
+
+		uuid := hatena.ExtractUUIDFromEntryID(createdEntry.ID)
+		report.UUID = uuid
+		report.URL = createdEntry.URL
+		if uuid != "" {
+			if err := article.UpdateArticleUUID(localArticle, uuid); err != nil {
+				logger.Printf("Warning: failed to update UUID in file %s: %v", localArticle.FilePath, err)
+			} else {
+				s.recordSync(localArticle, createdEntry)
+			}
+		}
+
+		s.logAction(logger, "+ %s", localArticle.FilePath)
+		result.count("create")
+
+	case "update":
+		localArticle := action.Article
+		remoteEntry := action.RemoteEntry
+		entryID := hatena.ExtractEntryIDFromEditURL(remoteEntry.EditURL)
+		if entryID == "" {
+			err := fmt.Errorf("failed to extract entry ID from edit URL: %s", remoteEntry.EditURL)
+			report.fail(result, err)
+			return nil
+		}
+
+		updatedEntry, err := set.client.UpdateEntryContext(ctx, entryID, localArticle)
+		if err != nil {
+			err = fmt.Errorf("failed to update article %s: %w", localArticle.Title, err)
+			report.fail(result, err)
+			return nil
+		}
+		report.URL = updatedEntry.URL
+		s.recordSync(localArticle, updatedEntry)
+		s.logAction(logger, "~ %s", localArticle.FilePath)
+		result.count("update")
+
+		if s.redirectStubs && remoteEntry.Path != "" && updatedEntry.Path != remoteEntry.Path {
+			// The entry itself was updated, so this is not the action's error,
+			// but the daily posting limit still stops the run
+			stub, err := set.client.CreateEntryContext(ctx, redirectStub(remoteEntry, updatedEntry))
+			if err != nil {
+				if isDailyLimitExceeded(err) {
+					return fmt.Errorf("daily posting limit exceeded: failed to create redirect from %s: %w", remoteEntry.URL, err)
+				}
+				result.addError(fmt.Errorf("failed to create redirect from %s: %w", remoteEntry.URL, err))
+				return nil
+			}
+			report.RedirectURL = stub.URL
+			s.logAction(logger, "  redirect %s -> %s", stub.URL, updatedEntry.URL)
+		}
+
+	case "pull":
+		localArticle := action.Article
+		pulled, err := articleFromEntry(action.RemoteEntry)
+		if err != nil {
+			report.fail(result, err)
+			return nil
+		}
+
+		keepUnsetFields(pulled, localArticle)
+		pulled.FilePath = localArticle.FilePath
+
+		if err := article.Save(pulled); err != nil {
+			err = fmt.Errorf("failed to pull article %s: %w", pulled.Title, err)
+			report.fail(result, err)
+			return nil
+		}
+		pulled.Blog = localArticle.Blog
+		*localArticle = *pulled
+		s.recordSync(localArticle, action.RemoteEntry)
+		s.logAction(logger, "< %s", localArticle.FilePath)
+		result.count("pull")
+
+	case "adopt":
+		localArticle := action.Article
+		remoteEntry := action.RemoteEntry
+		uuid := hatena.ExtractUUIDFromEntryID(remoteEntry.ID)
+		if err := article.UpdateArticleUUID(localArticle, uuid); err != nil {
+			err = fmt.Errorf("failed to link %s to %s: %w", localArticle.FilePath, remoteEntry.URL, err)
+			report.fail(result, err)
+			return nil
+		}
+		report.UUID = uuid
+
+		if s.needsUpdate(localArticle, remoteEntry) {
+			entryID := hatena.ExtractEntryIDFromEditURL(remoteEntry.EditURL)
+			updatedEntry, err := set.client.UpdateEntryContext(ctx, entryID, localArticle)
+			if err != nil {
+				err = fmt.Errorf("failed to update linked article %s: %w", localArticle.Title, err)
+				report.fail(result, err)
+				return nil
+			}
+			remoteEntry = updatedEntry
+			report.URL = updatedEntry.URL
+		}
+		s.recordSync(localArticle, remoteEntry)
+		s.logAction(logger, "@ %s", localArticle.FilePath)
+		result.count("adopt")
+
+	case "skip":
+		switch {
+		case action.Article.UUID == "":
+			logger.Printf("Warning: %s was not synced: %s", action.Article.FilePath, action.Reason)
+		case action.RemoteEntry == nil:
+			report.Reason = "UUID not found in remote"
+			logger.Printf("Warning: Article %s has UUID but not found in remote", action.Article.FilePath)
+		default:
+			s.recordSync(action.Article, action.RemoteEntry)
+			s.logAction(logger, "= %s", action.Article.FilePath)
+		}
+		result.count("skip")
+	}
+
+	return nil
+}
+
+// logAction writes the text line of an applied action, unless actions go
+// to a reporter instead.
+func (s *Syncer) logAction(logger *log.Logger, format string, v ...interface{}) {
+	if s.reporter != nil {
+		return
+	}
+	logger.Printf(format, v...)
+}
+
+// recordSync stores the remote state the article now matches, which is what
+// later runs compare against to tell local and remote edits apart.
+func (s *Syncer) recordSync(local *article.Article, remote *article.HatenaEntry) {
+	if s.state == nil {
+		return
+	}
+
+	s.state.Set(local.FilePath, state.Entry{
+		Blog:          s.blogOf(local),
+		UUID:          hatena.ExtractUUIDFromEntryID(remote.ID),
+		EntryID:       hatena.ExtractEntryIDFromEditURL(remote.EditURL),
+		EditURL:       remote.EditURL,
+		URL:           remote.URL,
+		ContentHash:   local.Hash(),
+		RemoteUpdated: remote.LastModified(),
+		RemoteHash:    remote.Hash(),
+	})
+}
+
+// remoteChangedSince tells whether remote was edited after the sync recorded
+// in baseline. The edited time has a resolution of a second, so the hash
+// catches edits made within the same second.
+func remoteChangedSince(baseline state.Entry, remote *article.HatenaEntry) bool {
+	if remote.LastModified() != baseline.RemoteUpdated {
+		return true
+	}
+	return baseline.RemoteHash != "" && baseline.RemoteHash != remote.Hash()
+}
+
+// baseline returns the recorded sync of the article, provided it is still
+// linked to the same remote entry.
+func (s *Syncer) baseline(local *article.Article) (state.Entry, bool) {
+	if s.state == nil {
+		return state.Entry{}, false
+	}
+
+	entry, ok := s.state.Get(local.FilePath)
+	if !ok || entry.UUID != local.UUID {
+		return state.Entry{}, false
+	}
+	return entry, true
+}
+
+// reportPlan reports planned actions, followed by the conflict report if
+// there are conflicts.
+func (s *Syncer) reportPlan(actions []DryRunAction) {
+	var conflicts []DryRunAction
+	for _, action := range actions {
+		if action.Type == "conflict" {
+			conflicts = append(conflicts, action)
+		}
+	}
+
+	if s.reporter != nil {
+		for i := range actions {
+			report := newActionReport(&actions[i])
+			if s.diff && diffsAction(&actions[i]) {
+				var b strings.Builder
+				s.writeDiff(&b, &actions[i], false)
+				report.Diff = b.String()
+			}
+			s.reporter.Action(report)
+		}
+		return
 	}
 
 	s.printDryRunReport(actions)
-	return result, nil
+	if len(conflicts) > 0 {
+		s.ReportConflicts(conflicts)
+	}
+}
+
+// reportConflicts reports conflicts that stopped a run.
+func (s *Syncer) reportConflicts(conflicts []DryRunAction) {
+	if s.reporter != nil {
+		for i := range conflicts {
+			s.reporter.Action(newActionReport(&conflicts[i]))
+		}
+		return
+	}
+	s.ReportConflicts(conflicts)
 }
 
 func (s *Syncer) printDryRunReport(actions []DryRunAction) {
-	for _, action := range actions {
+	var total diff.Stats
+	var changed int
+
+	for i, action := range actions {
 		switch action.Type {
 		case "create":
 			fmt.Printf("+ %s\n", action.Article.FilePath)
 		case "update":
 			fmt.Printf("~ %s\n", action.Article.FilePath)
+		case "pull":
+			fmt.Printf("< %s\n", action.Article.FilePath)
+		case "adopt":
+			fmt.Printf("@ %s\n", action.Article.FilePath)
 		case "skip":
 			fmt.Printf("= %s\n", action.Article.FilePath)
+		case "conflict":
+			fmt.Printf("! %s\n", action.Article.FilePath)
 		case "delete":
 			if action.RemoteEntry.URL != "" {
 				fmt.Printf("- %s\n", action.RemoteEntry.URL)
 			}
 		}
+
+		if s.diff && diffsAction(&actions[i]) {
+			fmt.Printf("  %s\n", action.Reason)
+			stats, err := s.writeDiff(os.Stdout, &actions[i], s.color)
+			if err != nil {
+				log.Printf("Warning: failed to write the diff of %s: %v", action.Article.FilePath, err)
+				continue
+			}
+			if stats.Added == 0 && stats.Removed == 0 {
+				// Adopting an entry that matches the file changes nothing
+				continue
+			}
+			fmt.Printf("  %d lines added, %d removed\n\n", stats.Added, stats.Removed)
+			total.Added += stats.Added
+			total.Removed += stats.Removed
+			changed++
+		}
+	}
+
+	if s.diff && changed > 0 {
+		fmt.Printf("Diff: %d articles, %d lines added, %d removed\n", changed, total.Added, total.Removed)
 	}
 }
 
+func (s *Syncer) ReportConflicts(conflicts []DryRunAction) {
+	fmt.Printf("\n=== CONFLICTS DETECTED ===\n")
+	fmt.Printf("%d articles were changed both locally and on Hatena Blog since the last sync:\n\n", len(conflicts))
+
+	for i, conflict := range conflicts {
+		fmt.Printf("%d. %s\n", i+1, conflict.Article.FilePath)
+		fmt.Printf("   %s\n", conflict.Reason)
+		fmt.Printf("   Differences: %v\n", describeChanges(conflict.Article, conflict.RemoteEntry))
+		if conflict.RemoteEntry.URL != "" {
+			fmt.Printf("   URL=%s\n", conflict.RemoteEntry.URL)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("Nothing was changed. Run `pull -overwrite` to take the remote version, then re-apply local edits and sync again.")
+	fmt.Print("=== END CONFLICT REPORT ===\n\n")
+}
+
+func describeChanges(local *article.Article, remote *article.HatenaEntry) []string {
+	var changes []string
+	if local.Title != remote.Title {
+		changes = append(changes, fmt.Sprintf("title: '%s' → '%s'", remote.Title, local.Title))
+	}
+	if !samePath(local, remote) {
+		changes = append(changes, fmt.Sprintf("path: '%s' → '%s'", remote.Path, local.Path))
+	}
+	if local.Content != remote.Content {
+		changes = append(changes, "content: modified")
+	}
+	if !equalCategories(local.Categories, remote.Categories) {
+		changes = append(changes, fmt.Sprintf("categories: %v → %v", remote.Categories, local.Categories))
+	}
+	if local.Draft != remote.IsDraft {
+		changes = append(changes, fmt.Sprintf("draft: %s → %s", yesNo(remote.IsDraft), yesNo(local.Draft)))
+	}
+	if !sameDate(local, remote) {
+		changes = append(changes, fmt.Sprintf("date: %s → %s", remote.Updated, local.DateTime.Format(time.RFC3339)))
+	}
+	return changes
+}
+
 func (s *Syncer) needsUpdate(local *article.Article, remote *article.HatenaEntry) bool {
 	if local.Title != remote.Title {
 		return true
@@ -268,9 +711,74 @@ func (s *Syncer) needsUpdate(local *article.Article, remote *article.HatenaEntry
 		return true
 	}
 
+	if !equalCategories(local.Categories, remote.Categories) {
+		return true
+	}
+
+	if local.Draft != remote.IsDraft {
+		return true
+	}
+
+	if !sameDate(local, remote) {
+		return true
+	}
+
+	if !samePath(local, remote) {
+		return true
+	}
+
 	return false
 }
 
+// equalCategories compares categories as sets, since their order carries no
+// meaning on Hatena Blog.
+func equalCategories(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := append([]string(nil), a...)
+	sortedB := append([]string(nil), b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameDate reports whether the remote entry is dated as the local article
+// asks. Articles without a date leave the remote date alone.
+func sameDate(local *article.Article, remote *article.HatenaEntry) bool {
+	if local.DateTime.IsZero() {
+		return true
+	}
+
+	remoteTime, err := time.Parse(time.RFC3339, remote.Updated)
+	if err != nil {
+		return false
+	}
+
+	return local.DateTime.Equal(remoteTime)
+}
+
+// samePath reports whether the remote entry has the custom URL the local
+// article asks for. Articles without a path leave the URL to Hatena Blog.
+func samePath(local *article.Article, remote *article.HatenaEntry) bool {
+	return local.Path == "" || local.Path == remote.Path
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
 func isDailyLimitExceeded(err error) bool {
 	if err == nil {
 		return false
@@ -282,12 +790,15 @@ func isDailyLimitExceeded(err error) bool {
 
 func (s *Syncer) FindDuplicateEntries(remoteEntries []*article.HatenaEntry) []DuplicateEntry {
 	titleMap := make(map[string][]*article.HatenaEntry)
-	
+
 	// Group entries by title
 	for _, entry := range remoteEntries {
+		if isRedirectStub(entry) {
+			continue
+		}
 		titleMap[entry.Title] = append(titleMap[entry.Title], entry)
 	}
-	
+
 	// Find duplicates
 	var duplicates []DuplicateEntry
 	for title, entries := range titleMap {
@@ -298,30 +809,30 @@ func (s *Syncer) FindDuplicateEntries(remoteEntries []*article.HatenaEntry) []Du
 			})
 		}
 	}
-	
+
 	return duplicates
 }
 
 func (s *Syncer) ReportDuplicateEntries(duplicates []DuplicateEntry) {
 	if len(duplicates) == 0 {
-		fmt.Println("No duplicate entries found.")
+		fmt.Fprintln(os.Stderr, "No duplicate entries found.")
 		return
 	}
-	
-	fmt.Printf("\n=== DUPLICATE ENTRIES DETECTED ===\n")
-	fmt.Printf("Found %d titles with multiple entries:\n\n", len(duplicates))
-	
+
+	fmt.Fprintf(os.Stderr, "\n=== DUPLICATE ENTRIES DETECTED ===\n")
+	fmt.Fprintf(os.Stderr, "Found %d titles with multiple entries:\n\n", len(duplicates))
+
 	for i, dup := range duplicates {
-		fmt.Printf("%d. Title: \"%s\" (%d entries)\n", i+1, dup.Title, len(dup.Entries))
+		fmt.Fprintf(os.Stderr, "%d. Title: \"%s\" (%d entries)\n", i+1, dup.Title, len(dup.Entries))
 		for j, entry := range dup.Entries {
 			uuid := hatena.ExtractUUIDFromEntryID(entry.ID)
-			fmt.Printf("   Entry %d: UUID=%s, Updated=%s\n", j+1, uuid, entry.Updated)
+			fmt.Fprintf(os.Stderr, "   Entry %d: UUID=%s, Updated=%s\n", j+1, uuid, entry.Updated)
 			if entry.URL != "" {
-				fmt.Printf("             URL=%s\n", entry.URL)
+				fmt.Fprintf(os.Stderr, "             URL=%s\n", entry.URL)
 			}
 		}
-		fmt.Println()
+		fmt.Fprintln(os.Stderr)
 	}
-	
-	fmt.Println("=== END DUPLICATE REPORT ===\n")
+
+	fmt.Fprint(os.Stderr, "=== END DUPLICATE REPORT ===\n\n")
 }
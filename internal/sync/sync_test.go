@@ -0,0 +1,262 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena/hatenamem"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
+)
+
+const testBlog = "example.hatenablog.com"
+
+// fixture is an article directory synced with an in-memory blog whose clock
+// only moves when told to.
+type fixture struct {
+	t    *testing.T
+	dir  string
+	blog *hatenamem.Blog
+	now  time.Time
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	f := &fixture{
+		t:    t,
+		dir:  t.TempDir(),
+		blog: hatenamem.New("me", testBlog),
+		now:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	f.blog.Now = func() time.Time { return f.now }
+	return f
+}
+
+func (f *fixture) tick() {
+	f.now = f.now.Add(time.Minute)
+}
+
+func (f *fixture) write(name, content string) {
+	f.t.Helper()
+
+	path := filepath.Join(f.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		f.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) read(name string) string {
+	f.t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(f.dir, name))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return string(data)
+}
+
+// replace edits a file in place, failing if old is not in it.
+func (f *fixture) replace(name, old, new string) {
+	f.t.Helper()
+
+	content := f.read(name)
+	if !strings.Contains(content, old) {
+		f.t.Fatalf("%s does not contain %q:\n%s", name, old, content)
+	}
+	f.write(name, strings.Replace(content, old, new, 1))
+}
+
+func (f *fixture) articles() []*article.Article {
+	f.t.Helper()
+
+	arts, err := article.LoadArticlesFromDir(f.dir, state.DirName)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if err := article.ResolveDates(arts, time.UTC); err != nil {
+		f.t.Fatal(err)
+	}
+	return arts
+}
+
+func (f *fixture) syncer(opts Options) *Syncer {
+	opts.StateDir = f.dir
+	opts.Blog = testBlog
+	return NewSyncerWithOptions(f.blog, opts)
+}
+
+func (f *fixture) sync(opts Options) (*SyncResult, error) {
+	return f.syncer(opts).SyncArticles(f.articles())
+}
+
+// mustSync syncs and fails the test on any error.
+func (f *fixture) mustSync(opts Options) *SyncResult {
+	f.t.Helper()
+
+	result, err := f.sync(opts)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if len(result.Errors) > 0 {
+		f.t.Fatal(result.Errors)
+	}
+	return result
+}
+
+// editRemote changes the only entry of the blog as the web editor would.
+func (f *fixture) editRemote(edit func(*article.HatenaEntry)) {
+	f.t.Helper()
+
+	entries := f.blog.Entries()
+	if len(entries) != 1 {
+		f.t.Fatalf("blog has %d entries, want 1", len(entries))
+	}
+	if err := f.blog.EditEntry(hatena.ExtractEntryIDFromEditURL(entries[0].EditURL), edit); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+// counts are the counters of a SyncResult.
+type counts struct {
+	Created, Updated, Pulled, Adopted, Skipped, Deleted, Conflicts int
+}
+
+func countsOf(r *SyncResult) counts {
+	return counts{r.Created, r.Updated, r.Pulled, r.Adopted, r.Skipped, r.Deleted, len(r.Conflicts)}
+}
+
+// syncTest syncs a.md once, changes the files or the blog and checks the
+// second sync.
+type syncTest struct {
+	// change runs between the first sync and the sync under test
+	change  func(f *fixture)
+	opts    Options
+	want    counts
+	wantErr bool
+	// errors is the number of errors collected in the result
+	errors int
+	check  func(t *testing.T, f *fixture)
+}
+
+func runSyncTests(t *testing.T, tests map[string]syncTest) {
+	t.Helper()
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFixture(t)
+			f.write("a.md", "---\ntitle: A\n---\nbody\n")
+			if got := countsOf(f.mustSync(Options{})); got != (counts{Created: 1}) {
+				t.Fatalf("first sync: got %+v", got)
+			}
+
+			if tt.change != nil {
+				tt.change(f)
+			}
+			result, err := f.sync(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			if result != nil {
+				if len(result.Errors) != tt.errors {
+					t.Errorf("got errors %v, want %d", result.Errors, tt.errors)
+				}
+				if got := countsOf(result); got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, f)
+			}
+		})
+	}
+}
+
+func TestSyncArticles(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"unchanged": {
+			want: counts{Skipped: 1},
+		},
+		"new file": {
+			change: func(f *fixture) { f.write("b.md", "---\ntitle: B\n---\nanother\n") },
+			want:   counts{Created: 1, Skipped: 1},
+			check: func(t *testing.T, f *fixture) {
+				if len(f.blog.Entries()) != 2 {
+					t.Errorf("blog has %d entries, want 2", len(f.blog.Entries()))
+				}
+				if !strings.Contains(f.read("b.md"), "uuid:") {
+					t.Errorf("b.md has no uuid:\n%s", f.read("b.md"))
+				}
+			},
+		},
+		"local edit": {
+			change: func(f *fixture) { f.replace("a.md", "body", "edited locally") },
+			want:   counts{Updated: 1},
+			check: func(t *testing.T, f *fixture) {
+				if content := f.blog.Entries()[0].Content; content != "edited locally" {
+					t.Errorf("remote content is %q", content)
+				}
+			},
+		},
+	})
+}
+
+func TestSyncConflicts(t *testing.T) {
+	runSyncTests(t, map[string]syncTest{
+		"remote edit": {
+			change: func(f *fixture) {
+				f.tick()
+				f.editRemote(func(e *article.HatenaEntry) { e.Content = "edited remotely" })
+			},
+			want: counts{Pulled: 1},
+			check: func(t *testing.T, f *fixture) {
+				if !strings.Contains(f.read("a.md"), "edited remotely") {
+					t.Errorf("a.md was not pulled:\n%s", f.read("a.md"))
+				}
+			},
+		},
+		"remote edit within the second of the sync": {
+			change: func(f *fixture) {
+				f.editRemote(func(e *article.HatenaEntry) { e.Content = "edited remotely" })
+			},
+			want: counts{Pulled: 1},
+			check: func(t *testing.T, f *fixture) {
+				if !strings.Contains(f.read("a.md"), "edited remotely") {
+					t.Errorf("a.md was not pulled:\n%s", f.read("a.md"))
+				}
+			},
+		},
+		"edited on both sides": {
+			change: func(f *fixture) {
+				f.replace("a.md", "body", "edited locally")
+				f.tick()
+				f.editRemote(func(e *article.HatenaEntry) { e.Content = "edited remotely" })
+			},
+			want:    counts{Conflicts: 1},
+			wantErr: true,
+			check: func(t *testing.T, f *fixture) {
+				if !strings.Contains(f.read("a.md"), "edited locally") {
+					t.Errorf("a.md was changed:\n%s", f.read("a.md"))
+				}
+				if content := f.blog.Entries()[0].Content; content != "edited remotely" {
+					t.Errorf("remote content is %q", content)
+				}
+			},
+		},
+		"edited the same way on both sides": {
+			change: func(f *fixture) {
+				f.replace("a.md", "body", "same edit")
+				f.tick()
+				f.editRemote(func(e *article.HatenaEntry) { e.Content = "same edit" })
+			},
+			want: counts{Skipped: 1},
+		},
+	})
+}
@@ -0,0 +1,58 @@
+// Package yamlnode edits yaml.v3 nodes in place, so that files can be
+// updated without losing comments, key order or unknown keys.
+package yamlnode
+
+import "gopkg.in/yaml.v3"
+
+// Root returns the top-level mapping of doc, turning an empty document into
+// one with an empty mapping. It returns nil if the document is not a mapping.
+func Root(doc *yaml.Node) *yaml.Node {
+	if doc.Kind == 0 {
+		*doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	return doc.Content[0]
+}
+
+// Value returns the value of key in mapping, or nil if it is missing.
+func Value(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Set sets key to value in place, appending it when missing. A nil value
+// removes the key.
+func Set(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		if value == nil {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		} else {
+			mapping.Content[i+1] = value
+		}
+		return
+	}
+
+	if value != nil {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	}
+}
+
+// Child returns the mapping under key, creating it if missing.
+func Child(mapping *yaml.Node, key string) *yaml.Node {
+	if child := Value(mapping, key); child != nil && child.Kind == yaml.MappingNode {
+		return child
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	Set(mapping, key, child)
+	return child
+}
@@ -1,72 +1,738 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/theoremoon/hatenablog-atompub-client/internal/article"
 	"github.com/theoremoon/hatenablog-atompub-client/internal/config"
 	"github.com/theoremoon/hatenablog-atompub-client/internal/hatena"
+	"github.com/theoremoon/hatenablog-atompub-client/internal/state"
 	"github.com/theoremoon/hatenablog-atompub-client/internal/sync"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Let a second signal kill the process the usual way
+		<-ctx.Done()
+		stop()
+	}()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "pull":
+			runPull(ctx, os.Args[2:])
+			return
+		case "plan":
+			runPlan(ctx, os.Args[2:])
+			return
+		case "apply":
+			runApply(ctx, os.Args[2:])
+			return
+		case "login":
+			runLogin(ctx, os.Args[2:])
+			return
+		case "dedupe":
+			runDedupe(ctx, os.Args[2:])
+			return
+		case "restore":
+			runRestore(ctx, os.Args[2:])
+			return
+		}
+	}
+
+	runSync(ctx, os.Args[1:])
+}
+
+func runSync(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	var articlesDir string
 	var dryRun bool
 	var deleteOrphan bool
-	flag.StringVar(&articlesDir, "dir", ".", "Directory containing article files")
-	flag.BoolVar(&dryRun, "dry-run", false, "Show what would be done without making any changes")
-	flag.BoolVar(&deleteOrphan, "delete-orphan", false, "Delete remote articles that no longer exist locally (DANGEROUS)")
-	flag.Parse()
+	var concurrency int
+	flags.StringVar(&articlesDir, "dir", ".", "Directory containing article files")
+	flags.BoolVar(&dryRun, "dry-run", false, "Show what would be done without making any changes")
+	var showDiff bool
+	flags.BoolVar(&showDiff, "diff", false, "Show a diff of what each update would change (implies -dry-run)")
+	var adopt string
+	flags.StringVar(&adopt, "adopt", sync.AdoptAsk, "Link articles without a uuid to a matching remote entry instead of creating a duplicate: ask, yes or no")
+	flags.BoolVar(&deleteOrphan, "delete-orphan", false, "Delete remote articles that no longer exist locally (DANGEROUS)")
+	flags.IntVar(&concurrency, "concurrency", 1, "Number of create/update/delete requests to run in parallel")
+	var redirectStubs bool
+	flags.BoolVar(&redirectStubs, "redirect-stubs", false, "Create an entry linking to the new URL at the old URL of entries whose path changes")
+	var cf clientFlags
+	cf.register(flags)
+	var out output
+	out.register(flags)
+	flags.Parse(args)
 
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Configuration error: %v", err)
+	cfg := cf.loadConfig(flags)
+	out.init()
+	if showDiff {
+		dryRun = true
 	}
+	checkAdopt(adopt)
 
-	articles, err := article.LoadArticlesFromDir(articlesDir)
-	if err != nil {
-		log.Fatalf("Failed to load articles: %v", err)
+	if concurrency < 1 {
+		log.Fatalf("-concurrency must be at least 1")
 	}
 
+	articles := loadArticles(articlesDir, cfg)
+
 	if len(articles) == 0 {
-		fmt.Println("No articles found")
+		fmt.Fprintln(os.Stderr, "No articles found")
+		out.finish(ctx, &sync.SyncResult{}, nil, "")
 		return
 	}
 
 	if deleteOrphan && !dryRun {
-		fmt.Print("WARNING: --delete-orphan is enabled. This will permanently delete remote articles that don't exist locally.\nAre you sure you want to continue? (y/N): ")
+		fmt.Fprint(os.Stderr, "WARNING: --delete-orphan is enabled. This will delete remote articles that don't exist locally.\nThey are backed up under .hatenasync/backup and can be re-created with the restore command.\nAre you sure you want to continue? (y/N): ")
 		var response string
 		fmt.Scanln(&response)
 		if response != "y" && response != "Y" && response != "yes" && response != "Yes" {
-			fmt.Println("Operation cancelled.")
+			fmt.Fprintln(os.Stderr, "Operation cancelled.")
 			os.Exit(0)
 		}
 	}
 
-	client := hatena.NewClient(cfg)
-	syncer := sync.NewSyncerWithDelete(client, deleteOrphan)
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{
+		DeleteOrphan:  deleteOrphan,
+		StateDir:      articlesDir,
+		Concurrency:   concurrency,
+		Blog:          cfg.BlogID,
+		ClientForBlog: cf.clientForBlog(cfg),
+		Reporter:      out.syncReporter(),
+		RedirectStubs: redirectStubs,
+		Adopt:         adopt,
+		ConfirmAdopt:  confirmAdopt(),
+		Diff:          showDiff,
+		Color:         colorOutput(),
+	})
 
 	var result *sync.SyncResult
+	var err error
 
 	if dryRun {
-		result, err = syncer.DryRunSyncArticles(articles)
+		result, err = syncer.DryRunSyncArticlesContext(ctx, articles)
 	} else {
-		result, err = syncer.SyncArticles(articles)
+		result, err = syncer.SyncArticlesContext(ctx, articles)
+	}
+	out.finish(ctx, result, err, "Synchronization failed")
+}
+
+// runPlan writes what a sync would do to a file for review, without
+// changing anything.
+func runPlan(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" plan", flag.ExitOnError)
+	var articlesDir string
+	var out string
+	var deleteOrphan bool
+	flags.StringVar(&articlesDir, "dir", ".", "Directory containing article files")
+	flags.StringVar(&out, "out", "plan.json", "File to write the plan to")
+	flags.BoolVar(&deleteOrphan, "delete-orphan", false, "Plan deleting remote articles that no longer exist locally (DANGEROUS)")
+	var showDiff bool
+	flags.BoolVar(&showDiff, "diff", false, "Show a diff of what each update would change")
+	var adopt string
+	flags.StringVar(&adopt, "adopt", sync.AdoptAsk, "Plan linking articles without a uuid to a matching remote entry: ask or yes (both plan the link), or no")
+	var cf clientFlags
+	cf.register(flags)
+	flags.Parse(args)
+
+	cfg := cf.loadConfig(flags)
+	checkAdopt(adopt)
+	articles := loadArticles(articlesDir, cfg)
+
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{
+		DeleteOrphan:  deleteOrphan,
+		StateDir:      articlesDir,
+		Blog:          cfg.BlogID,
+		ClientForBlog: cf.clientForBlog(cfg),
+		Adopt:         adopt,
+		Diff:          showDiff,
+		Color:         colorOutput(),
+	})
+
+	plan, err := syncer.PlanContext(ctx, articles)
+	if err != nil {
+		exitInterrupted(ctx, nil)
+		log.Fatalf("Planning failed: %v", err)
+	}
+
+	if conflicts := plan.Conflicts(); len(conflicts) > 0 {
+		log.Fatalf("Not writing a plan with %d conflicts", len(conflicts))
+	}
+
+	if err := sync.WritePlan(out, plan); err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("Plan with %d actions written to %s. Run `apply %s` to carry it out.\n", len(plan.Actions), out, out)
+}
+
+// runApply carries out a plan written by runPlan, provided nothing changed
+// since.
+func runApply(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" apply", flag.ExitOnError)
+	var articlesDir string
+	var concurrency int
+	flags.StringVar(&articlesDir, "dir", "", "Directory containing article files (default: the directory of the plan)")
+	flags.IntVar(&concurrency, "concurrency", 1, "Number of create/update/delete requests to run in parallel")
+	var redirectStubs bool
+	flags.BoolVar(&redirectStubs, "redirect-stubs", false, "Create an entry linking to the new URL at the old URL of entries whose path changes")
+	var cf clientFlags
+	cf.register(flags)
+	var out output
+	out.register(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s apply [options] plan.json\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	plan, err := sync.ReadPlan(flags.Arg(0))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if articlesDir == "" {
+		// The plan's directory takes precedence over the profile's dir
+		flags.Set("dir", plan.Dir)
+	}
+
+	cfg := cf.loadConfig(flags)
+	out.init()
+	if concurrency < 1 {
+		log.Fatalf("-concurrency must be at least 1")
+	}
+
+	articles := loadArticles(articlesDir, cfg)
+
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{
+		DeleteOrphan:  plan.DeleteOrphan,
+		Adopt:         plan.Adopt,
+		StateDir:      articlesDir,
+		Concurrency:   concurrency,
+		Blog:          cfg.BlogID,
+		ClientForBlog: cf.clientForBlog(cfg),
+		Reporter:      out.syncReporter(),
+		RedirectStubs: redirectStubs,
+	})
+
+	result, err := syncer.ApplyPlanContext(ctx, plan, articles)
+	out.finish(ctx, result, err, "Apply failed")
+}
+
+// runDedupe deletes the copies of duplicated entries that no local file is
+// linked to, group by group.
+func runDedupe(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" dedupe", flag.ExitOnError)
+	var articlesDir string
+	var keep string
+	var yes bool
+	var dryRun bool
+	var minSimilarity float64
+	flags.StringVar(&articlesDir, "dir", ".", "Directory containing article files")
+	flags.StringVar(&keep, "keep", sync.KeepLinked, "Copy to keep besides those linked to a local file: linked (the newest only if none is linked) or newest")
+	flags.Float64Var(&minSimilarity, "min-similarity", 90, "Keep copies whose content is less similar than this percentage to the kept copy, as they are likely different posts")
+	flags.BoolVar(&yes, "yes", false, "Delete without asking")
+	flags.BoolVar(&dryRun, "dry-run", false, "Show the duplicates and what would be deleted without deleting anything")
+	var cf clientFlags
+	cf.register(flags)
+	flags.Parse(args)
+
+	cfg := cf.loadConfig(flags)
+	if keep != sync.KeepLinked && keep != sync.KeepNewest {
+		log.Fatalf("invalid -keep %q (use %s or %s)", keep, sync.KeepLinked, sync.KeepNewest)
+	}
+	if minSimilarity < 0 || minSimilarity > 100 {
+		log.Fatalf("invalid -min-similarity %v (use a percentage from 0 to 100)", minSimilarity)
+	}
+	if !yes && !dryRun && !isTerminal(os.Stdin) {
+		log.Fatalf("Cannot ask for confirmation; use -yes or -dry-run")
+	}
+
+	articles := loadArticles(articlesDir, cfg)
+
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{
+		StateDir:      articlesDir,
+		Blog:          cfg.BlogID,
+		ClientForBlog: cf.clientForBlog(cfg),
+	})
+
+	groups, err := syncer.FindDuplicatesContext(ctx, articles, keep, minSimilarity/100)
+	if err != nil {
+		exitInterrupted(ctx, nil)
+		log.Fatalf("Failed to find duplicates: %v", err)
+	}
+	if len(groups) == 0 {
+		fmt.Println("No duplicate entries found.")
+		return
+	}
+
+	if dryRun {
+		var deletions int
+		for i, group := range groups {
+			syncer.ReportDuplicateGroup(i+1, group)
+			deletions += len(group.Deletions())
+		}
+		fmt.Printf("%d duplicate titles, %d copies would be deleted\n", len(groups), deletions)
+		return
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	result := &sync.SyncResult{}
+	for i, group := range groups {
+		syncer.ReportDuplicateGroup(i+1, group)
+
+		deletions := len(group.Deletions())
+		if deletions == 0 {
+			continue
+		}
+		if !yes {
+			fmt.Fprintf(os.Stderr, "Delete %d copies of %q? (y/N): ", deletions, group.Title)
+			line, _ := stdin.ReadString('\n')
+			response := strings.TrimSpace(line)
+			if response != "y" && response != "Y" && response != "yes" && response != "Yes" {
+				fmt.Fprintln(os.Stderr, "Skipped.")
+				continue
+			}
+		}
+
+		if err := syncer.DeleteDuplicatesContext(ctx, group, result); err != nil {
+			exitInterrupted(ctx, result)
+			log.Fatalf("Dedupe failed: %v", err)
+		}
+	}
+
+	printResult(result)
+}
+
+func runPull(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" pull", flag.ExitOnError)
+	var articlesDir string
+	var filenameTemplate string
+	var overwrite bool
+	flags.StringVar(&articlesDir, "dir", ".", "Directory to write article files into")
+	flags.StringVar(&filenameTemplate, "filename", sync.DefaultFilenameTemplate, "Filename template for new articles (fields: .Slug, .Path, .UUID, .Title, .Date)")
+	flags.BoolVar(&overwrite, "overwrite", false, "Overwrite local files that differ from their remote entry")
+	var cf clientFlags
+	cf.register(flags)
+	flags.Parse(args)
+
+	cfg := cf.loadConfig(flags)
+
+	if err := os.MkdirAll(articlesDir, 0755); err != nil {
+		log.Fatalf("Failed to create directory: %v", err)
+	}
+
+	articles := loadArticles(articlesDir, cfg)
+
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{StateDir: articlesDir, Blog: cfg.BlogID})
+
+	result, err := syncer.PullArticlesContext(ctx, articles, articlesDir, filenameTemplate, overwrite)
+	if err != nil {
+		exitInterrupted(ctx, result)
+		log.Fatalf("Pull failed: %v", err)
+	}
+
+	printResult(result)
+}
+
+// runRestore re-creates entries from a backup taken before they were
+// deleted.
+func runRestore(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s restore [options] <backup dir> [uuid...]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	var articlesDir string
+	var filenameTemplate string
+	var dryRun bool
+	flags.StringVar(&articlesDir, "dir", ".", "Directory to write restored article files into")
+	flags.StringVar(&filenameTemplate, "filename", sync.DefaultFilenameTemplate, "Filename template for restored articles (fields: .Slug, .Path, .UUID, .Title, .Date)")
+	flags.BoolVar(&dryRun, "dry-run", false, "Show what would be restored without creating anything")
+	var cf clientFlags
+	cf.register(flags)
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	backupDir, uuids := flags.Arg(0), flags.Args()[1:]
+
+	cfg := cf.loadConfig(flags)
+
+	client := cf.newClient(cfg)
+	syncer := sync.NewSyncerWithOptions(client, sync.Options{
+		StateDir:      articlesDir,
+		Blog:          cfg.BlogID,
+		ClientForBlog: cf.clientForBlog(cfg),
+	})
+
+	result, err := syncer.RestoreContext(ctx, backupDir, articlesDir, filenameTemplate, uuids, dryRun)
+	if err != nil {
+		exitInterrupted(ctx, result)
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	printResult(result)
+}
+
+// runLogin authorizes the client to act on behalf of a Hatena user with
+// OAuth's out-of-band flow and stores the access token in a config profile.
+func runLogin(ctx context.Context, args []string) {
+	flags := flag.NewFlagSet(os.Args[0]+" login", flag.ExitOnError)
+	var profileName string
+	var configPath string
+	var consumerKey string
+	var consumerSecret string
+	var scope string
+	flags.StringVar(&profileName, "profile", "", "Profile to store the access token in (default: HATENA_PROFILE or \"default\")")
+	flags.StringVar(&configPath, "config", "", "Config file to write (default: ~/.config/hatenablog/config.yaml)")
+	flags.StringVar(&consumerKey, "consumer-key", "", "OAuth consumer key (default: HATENA_CONSUMER_KEY or oauth_consumer_key of the profile)")
+	flags.StringVar(&consumerSecret, "consumer-secret", "", "OAuth consumer secret (default: HATENA_CONSUMER_SECRET or oauth_consumer_secret of the profile)")
+	flags.StringVar(&scope, "scope", hatena.DefaultOAuthScope, "Comma-separated OAuth scopes to request")
+	flags.Parse(args)
+
+	if profileName == "" {
+		profileName = os.Getenv("HATENA_PROFILE")
+	}
+	if profileName == "" {
+		profileName = "default"
+	}
+
+	if configPath == "" {
+		path, err := config.UserFilePath()
+		if err != nil {
+			log.Fatalf("Failed to locate the config file: %v", err)
+		}
+		configPath = path
+	}
+
+	file, err := config.ReadFile(configPath)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	var existing config.Profile
+	if file != nil {
+		existing = file.Profiles[profileName]
+	}
+
+	consumerKey = firstNonEmpty(consumerKey, os.Getenv("HATENA_CONSUMER_KEY"), existing.OAuthConsumerKey)
+	consumerSecret = firstNonEmpty(consumerSecret, os.Getenv("HATENA_CONSUMER_SECRET"), existing.OAuthConsumerSecret)
+	if consumerKey == "" || consumerSecret == "" {
+		log.Fatalf("An OAuth consumer key and secret are required; register an application at https://www.hatena.ne.jp/oauth/develop")
+	}
+
+	temporary, err := hatena.RequestTemporaryCredentials(ctx, consumerKey, consumerSecret, scope)
+	if err != nil {
+		log.Fatalf("Failed to start authorization: %v", err)
+	}
+
+	fmt.Printf("Open this URL in your browser and allow access:\n\n  %s\n\nEnter the verification code shown: ", hatena.OAuthAuthorizationURL(temporary))
+	var verifier string
+	fmt.Scanln(&verifier)
+	if verifier == "" {
+		fmt.Println("Login cancelled.")
+		os.Exit(1)
+	}
+
+	token, err := hatena.RequestAccessToken(ctx, consumerKey, consumerSecret, temporary, verifier)
+	if err != nil {
+		log.Fatalf("Failed to get an access token: %v", err)
+	}
+
+	update := config.Profile{
+		Auth:                config.AuthOAuth,
+		OAuthConsumerKey:    consumerKey,
+		OAuthConsumerSecret: consumerSecret,
+		OAuthToken:          token.Token,
+		OAuthTokenSecret:    token.TokenSecret,
+	}
+	if existing.HatenaID == "" {
+		update.HatenaID = token.UserName
+	}
+	if err := config.UpdateProfile(configPath, profileName, update); err != nil {
+		log.Fatalf("Failed to save the access token: %v", err)
+	}
+
+	fmt.Printf("Logged in as %s. Saved the access token to profile %q in %s\n", token.UserName, profileName, configPath)
+	if existing.BlogID == "" {
+		fmt.Println("Set blog_id in the profile to choose the blog to sync.")
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
 	}
+	return ""
+}
+
+// loadArticles reads the articles of dir and resolves their dates and
+// target blogs.
+func loadArticles(dir string, cfg *config.Config) []*article.Article {
+	// The state directory holds backups of deleted entries, not articles
+	articles, err := article.LoadArticlesFromDir(dir, state.DirName)
+	if err != nil {
+		log.Fatalf("Failed to load articles: %v", err)
+	}
+
+	if err := article.ResolveDates(articles, cfg.Location); err != nil {
+		log.Fatalf("Failed to load articles: %v", err)
+	}
+
+	if err := article.ResolveBlogs(articles, dir); err != nil {
+		log.Fatalf("Failed to load articles: %v", err)
+	}
+
+	return articles
+}
+
+// clientFlags are the API client settings shared by every command.
+type clientFlags struct {
+	profile string
+	verbose bool
+	retries int
+	rate    float64
+	burst   int
+
+	// limiter is shared by the clients of every blog
+	limiter *hatena.RateLimiter
+}
+
+func (cf *clientFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&cf.profile, "profile", "", "Config file profile to use (default: HATENA_PROFILE, default_profile or \"default\")")
+	flags.BoolVar(&cf.verbose, "verbose", false, "Log retries and other API diagnostics")
+	flags.IntVar(&cf.retries, "retries", hatena.DefaultRetryPolicy.MaxAttempts, "Maximum attempts per API request, including the first")
+	flags.Float64Var(&cf.rate, "rate", hatena.DefaultRequestsPerSecond, "Maximum API requests per second (0 for no limit)")
+	flags.IntVar(&cf.burst, "burst", hatena.DefaultBurst, "Number of API requests allowed in a burst above -rate")
+}
+
+// loadConfig loads the selected profile and applies its dir and defaults to
+// the flags that were not given on the command line.
+func (cf *clientFlags) loadConfig(flags *flag.FlagSet) *config.Config {
+	cfg, err := config.LoadProfile(cf.profile)
 	if err != nil {
-		log.Fatalf("Synchronization failed: %v", err)
+		log.Fatalf("Configuration error: %v", err)
 	}
 
-	fmt.Printf("Created: %d, Updated: %d, Skipped: %d, Deleted: %d, Errors: %d\n",
-		result.Created, result.Updated, result.Skipped, result.Deleted, len(result.Errors))
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	defaults := make(map[string]string)
+	for name, value := range cfg.Defaults {
+		defaults[name] = value
+	}
+	if cfg.Dir != "" {
+		defaults["dir"] = cfg.Dir
+	}
 
-	if len(result.Errors) > 0 {
-		for _, err := range result.Errors {
-			fmt.Printf("Error: %v\n", err)
+	for name, value := range defaults {
+		// Defaults may name flags of other commands
+		if set[name] || name == "profile" || flags.Lookup(name) == nil {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			log.Fatalf("Configuration error: invalid default for -%s in profile %s: %v", name, cfg.Profile, err)
 		}
+	}
+
+	return cfg
+}
+
+func (cf *clientFlags) newClient(cfg *config.Config) *hatena.Client {
+	policy := hatena.DefaultRetryPolicy
+	policy.MaxAttempts = cf.retries
+
+	if cf.limiter == nil {
+		cf.limiter = hatena.NewRateLimiter(cf.rate, cf.burst)
+	}
+
+	opts := []hatena.Option{
+		hatena.WithRetryPolicy(policy),
+		hatena.WithRateLimiter(cf.limiter),
+	}
+	if cf.verbose {
+		opts = append(opts, hatena.WithLogger(log.Default()))
+	}
+
+	client, err := hatena.NewClient(cfg, opts...)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	return client
+}
+
+// clientForBlog returns clients for other blogs of the same account, for
+// articles that target them.
+func (cf *clientFlags) clientForBlog(cfg *config.Config) func(blog string) (hatena.AtomPubClient, error) {
+	return func(blog string) (hatena.AtomPubClient, error) {
+		blogConfig := *cfg
+		blogConfig.BlogID = blog
+		return cf.newClient(&blogConfig), nil
+	}
+}
+
+// exitInterrupted reports what was done before a signal cancelled ctx and
+// exits. It returns if ctx was not cancelled.
+func exitInterrupted(ctx context.Context, result *sync.SyncResult) {
+	if ctx.Err() == nil {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Interrupted.")
+	if result != nil {
+		printSummary(result)
+	}
+	os.Exit(130)
+}
+
+// output writes the actions and result of a run as text, or as JSON for
+// scripts. Only the output goes to stdout; progress and diagnostics are
+// logged to stderr.
+type output struct {
+	format   string
+	reporter *sync.JSONReporter
+}
+
+func (o *output) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.format, "output", "text", "Output format: text, json (one document at the end) or ndjson (one line per action)")
+}
+
+func (o *output) init() {
+	switch o.format {
+	case "text":
+	case "json":
+		o.reporter = sync.NewJSONReporter(os.Stdout, false)
+	case "ndjson":
+		o.reporter = sync.NewJSONReporter(os.Stdout, true)
+	default:
+		log.Fatalf("invalid -output %q (use text, json or ndjson)", o.format)
+	}
+}
+
+// syncReporter returns the reporter for the syncer, which is nil for text.
+func (o *output) syncReporter() sync.Reporter {
+	if o.reporter == nil {
+		return nil
+	}
+	return o.reporter
+}
+
+// finish writes the result of a run that ended with err, if any, and exits
+// unless the run succeeded.
+func (o *output) finish(ctx context.Context, result *sync.SyncResult, err error, failure string) {
+	if o.reporter == nil {
+		if err != nil {
+			exitInterrupted(ctx, result)
+			log.Fatalf("%s: %v", failure, err)
+		}
+		printResult(result)
+		return
+	}
+
+	interrupted := ctx.Err() != nil
+	if err != nil && !interrupted {
+		log.Printf("%s: %v", failure, err)
+	}
+	if writeErr := o.reporter.Finish(result, err, interrupted); writeErr != nil {
+		log.Fatalf("Failed to write output: %v", writeErr)
+	}
+
+	switch {
+	case interrupted:
+		os.Exit(130)
+	case err != nil:
+		os.Exit(1)
+	case len(result.Errors) > 0 || len(result.Conflicts) > 0:
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
+
+// colorOutput reports whether stdout is a terminal that diffs may be
+// colored on. NO_COLOR turns color off.
+func colorOutput() bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// isTerminal reports whether f looks like a terminal: a character device
+// other than the null device, which is where CI jobs often point stdin.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	if null, err := os.Stat(os.DevNull); err == nil && os.SameFile(info, null) {
+		return false
+	}
+	return true
+}
+
+func checkAdopt(adopt string) {
+	switch adopt {
+	case sync.AdoptAsk, sync.AdoptYes, sync.AdoptNo:
+	default:
+		log.Fatalf("invalid -adopt %q (use %s, %s or %s)", adopt, sync.AdoptAsk, sync.AdoptYes, sync.AdoptNo)
+	}
+}
+
+// confirmAdopt returns a prompt asking whether to link an article without a
+// uuid to the remote entry it matches, or nil if stdin is not a terminal.
+func confirmAdopt() func(local *article.Article, remote *article.HatenaEntry) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	return func(local *article.Article, remote *article.HatenaEntry) (bool, error) {
+		fmt.Fprintf(os.Stderr, "%s has no uuid but matches the existing entry %q at %s.\nLink it instead of creating a new entry? (y/N): ", local.FilePath, remote.Title, remote.URL)
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			// No answer at all, as on end of input
+			return false, err
+		}
+		response := strings.TrimSpace(line)
+		return response == "y" || response == "Y" || response == "yes" || response == "Yes", nil
+	}
+}
+
+func printResult(result *sync.SyncResult) {
+	printSummary(result)
+	if len(result.Errors) > 0 || len(result.Conflicts) > 0 {
+		os.Exit(1)
+	}
+}
+
+func printSummary(result *sync.SyncResult) {
+	fmt.Printf("Created: %d, Updated: %d, Pulled: %d, Adopted: %d, Skipped: %d, Deleted: %d, Conflicts: %d, Errors: %d\n",
+		result.Created, result.Updated, result.Pulled, result.Adopted, result.Skipped, result.Deleted, len(result.Conflicts), len(result.Errors))
+
+	for _, err := range result.Errors {
+		fmt.Printf("Error: %v\n", err)
+	}
+}